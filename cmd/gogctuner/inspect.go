@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fangwentong/gogctuner"
+	"github.com/fangwentong/gogctuner/internal/cgroup"
+	"github.com/fangwentong/gogctuner/internal/memory"
+)
+
+type (
+	inspectReport struct {
+		PID        string        `json:"pid"`
+		Cgroup     cgroup.Report `json:"cgroup"`
+		HostTotal  uint64        `json:"host_total"`
+		Candidates []candidate   `json:"candidates"`
+		// MemoryLimit is the winner of the candidates
+		MemoryLimit       uint64 `json:"memory_limit"`
+		MemoryLimitSource string `json:"memory_limit_source"`
+		// DetectedMemoryLimit is the memory limit detected by the tuner
+		DetectedMemoryLimit uint64             `json:"detected_memory_limit"`
+		Usage               uint64             `json:"usage"`
+		Free                uint64             `json:"free"`
+		SwapLimit           uint64             `json:"swap_limit"`
+		SwapUsage           uint64             `json:"swap_usage"`
+		Config              gogctuner.Config   `json:"config"`
+		Decisions           []strategyDecision `json:"decisions,omitempty"`
+	}
+
+	candidate struct {
+		Source string `json:"source"`
+		Name   string `json:"name"`
+		Value  int64  `json:"value"`
+		Valid  bool   `json:"valid"`
+	}
+
+	strategyDecision struct {
+		Strategy    string `json:"strategy"`
+		GOGC        int    `json:"gogc"`
+		MemoryLimit int64  `json:"memory_limit"`
+	}
+)
+
+func runInspect(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	var (
+		pid      = fs.String("pid", "self", "inspect the cgroup of another process")
+		root     = fs.String("root", "", "directory where the root filesystem is mounted, e.g. /host in a privileged sidecar")
+		jsonOut  = fs.Bool("json", false, "print the report in JSON")
+		liveHeap byteSize
+		report   inspectReport
+	)
+	fs.Var(&liveHeap, "live-heap", "live heap size used to compute GOGC before go1.19, e.g. 512MiB")
+	addConfigFlags(fs, &report.Config)
+	_ = fs.Parse(args)
+
+	if err := report.Config.CheckValid(); err != nil {
+		return err
+	}
+	if *pid != "self" {
+		if _, err := strconv.Atoi(*pid); err != nil {
+			return fmt.Errorf("invalid pid %q", *pid)
+		}
+	}
+
+	reader := memory.Reader{FS: cgroup.FS{Root: *root, PID: *pid}}
+	report.PID = *pid
+	report.Cgroup = reader.FS.Inspect()
+	report.HostTotal = reader.HostTotalMemory()
+	report.inspectLimits(reader)
+	report.DetectedMemoryLimit = reader.GetMemoryLimit()
+	report.SwapLimit = reader.GetSwapLimit()
+	report.SwapUsage = reader.GetSwapUsage()
+	if report.Config.MaxRAMPercentage > 0 || report.Config.GOGC != 0 {
+		report.decide(uint64(liveHeap))
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	report.print(os.Stdout)
+	return nil
+}
+
+// inspectLimits collects the candidates of the memory limit, and selects the winner like the tuner does
+func (r *inspectReport) inspectLimits(reader memory.Reader) {
+	var cgroupLimit, hierarchicalLimit, usage int64
+	switch r.Cgroup.Version {
+	case 1:
+		cgroupLimit = r.addCandidate(memory.SourceCgroup, "memory.limit_in_bytes")
+		hierarchicalLimit = r.addCandidate(memory.SourceHierarchical, "hierarchical_memory_limit")
+		usage = r.stat("memory.usage_in_bytes")
+	case 2:
+		cgroupLimit = r.addCandidate(memory.SourceCgroup, "memory.max")
+		r.addCandidate("", "memory.high")
+		usage = r.stat("memory.current")
+	}
+	r.Candidates = append(r.Candidates, candidate{
+		Source: memory.SourceHost, Name: "total memory", Value: int64(r.HostTotal), Valid: r.HostTotal > 0,
+	})
+
+	r.MemoryLimit, r.MemoryLimitSource = memory.SelectMemoryLimit(r.HostTotal, cgroupLimit, func() int64 {
+		return hierarchicalLimit
+	})
+	if usage > 0 && uint64(usage) <= r.MemoryLimit {
+		r.Usage, r.Free = uint64(usage), r.MemoryLimit-uint64(usage)
+	} else if free := reader.HostFreeMemory(); free <= r.HostTotal {
+		r.Usage, r.Free = r.HostTotal-free, free
+	}
+}
+
+func (r *inspectReport) addCandidate(source, name string) int64 {
+	v := r.stat(name)
+	c := candidate{Source: source, Name: name, Value: v}
+	if source != "" {
+		limit, _ := memory.SelectMemoryLimit(r.HostTotal, v, func() int64 { return 0 })
+		c.Valid = v > 0 && limit == uint64(v)
+	}
+	r.Candidates = append(r.Candidates, c)
+	return v
+}
+
+// stat returns the value of the cgroup stat, or 0 if it's unavailable or unlimited
+func (r *inspectReport) stat(name string) int64 {
+	s, ok := r.Cgroup.Stat(name)
+	if !ok || s.Unlimited || s.Error != "" {
+		return 0
+	}
+	return s.Value
+}
+
+func (r *inspectReport) decide(liveHeap uint64) {
+	input := gogctuner.StrategyInput{MemoryLimit: r.MemoryLimit, LiveHeap: liveHeap}
+	if r.Config.IncludeSwap {
+		input.MemoryLimit += r.SwapLimit
+	}
+	for _, name := range []string{"gogc", "memory-limit"} {
+		s, _ := parseStrategy(name)
+		d := s.Decide(r.Config.ForMemoryLimit(input.MemoryLimit), input)
+		r.Decisions = append(r.Decisions, strategyDecision{Strategy: name, GOGC: d.GOGC, MemoryLimit: d.MemoryLimit})
+	}
+}
+
+func (r *inspectReport) print(w io.Writer) {
+	fmt.Fprintf(w, "process: %s\n", r.PID)
+	if r.Cgroup.Version == 0 {
+		fmt.Fprintf(w, "cgroup: not found\n")
+	} else {
+		fmt.Fprintf(w, "cgroup: v%d, memory cgroup dir %s\n", r.Cgroup.Version, r.Cgroup.Dir)
+	}
+	fmt.Fprintf(w, "\nmounts:\n")
+	for _, m := range r.Cgroup.Mounts {
+		fmt.Fprintf(w, "  cgroup v%d %s (root %s) %s\n", m.Version, m.MountPoint, m.Root, strings.Join(m.Controllers, ","))
+	}
+	fmt.Fprintf(w, "\ncgroup paths:\n")
+	for _, p := range r.Cgroup.Paths {
+		fmt.Fprintf(w, "  %s:%s:%s\n", p.HierarchyID, strings.Join(p.Controllers, ","), p.Path)
+	}
+	fmt.Fprintf(w, "\nmemory limit candidates:\n")
+	for _, c := range r.Candidates {
+		value := formatBytes(uint64(c.Value))
+		if s, ok := r.Cgroup.Stat(c.Name); ok && s.Error != "" {
+			value = "error: " + s.Error
+		} else if ok && s.Unlimited {
+			value = "max"
+		}
+		mark := " "
+		if c.Source != "" && c.Source == r.MemoryLimitSource {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s %-13s %-26s %s\n", mark, c.Source, c.Name, value)
+	}
+	fmt.Fprintf(w, "\nmemory limit: %s (%s)\n", formatBytes(r.MemoryLimit), r.MemoryLimitSource)
+	fmt.Fprintf(w, "detected by the tuner: %s\n", formatBytes(r.DetectedMemoryLimit))
+	fmt.Fprintf(w, "usage: %s, free: %s\n", formatBytes(r.Usage), formatBytes(r.Free))
+	fmt.Fprintf(w, "swap limit: %s, swap usage: %s\n", formatBytes(r.SwapLimit), formatBytes(r.SwapUsage))
+	for _, name := range []string{"memory.memsw.limit_in_bytes", "memory.memsw.usage_in_bytes", "memory.swap.max", "memory.swap.current"} {
+		if s, ok := r.Cgroup.Stat(name); ok && s.Error == "" {
+			value := formatBytes(uint64(s.Value))
+			if s.Unlimited {
+				value = "max"
+			}
+			fmt.Fprintf(w, "  %-26s %s\n", name, value)
+		}
+	}
+	if len(r.Decisions) > 0 {
+		fmt.Fprintf(w, "\nconfig %+v:\n", r.Config)
+		for _, d := range r.Decisions {
+			fmt.Fprintf(w, "  %-13s GOGC %d, memory limit %s\n", d.Strategy, d.GOGC, formatBytes(uint64(d.MemoryLimit)))
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Command gogctuner is a toolbox for gctuner.
+//
+// Usage:
+//
+//	gogctuner <command> [flags]
+//
+// The commands are:
+//
+//	inspect print the detected memory limits and the computed GC parameters
+//	sim     replay a heap time series against a tuner config
+//	whatif  evaluate a tuner config against GODEBUG=gctrace=1 output
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{name: "inspect", usage: "print the detected memory limits and the computed GC parameters", run: runInspect},
+	{name: "sim", usage: "replay a heap time series against a tuner config", run: runSim},
+	{name: "whatif", usage: "evaluate a tuner config against GODEBUG=gctrace=1 output", run: runWhatIf},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			if err := c.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "gogctuner %s: %v\n", c.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: gogctuner <command> [flags]\n\nThe commands are:\n\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "\t%-8s %s\n", c.name, c.usage)
+	}
+}
+
+// formatBytes prints memory size in a readable format
+func formatBytes(bytes uint64) string {
+	if bytes >= uint64(math.MaxInt64) {
+		return "unlimited"
+	}
+	const (
+		KB = 1 << 10
+		MB = 1 << 20
+		GB = 1 << 30
+	)
+	switch {
+	case bytes < KB:
+		return fmt.Sprintf("%dB", bytes)
+	case bytes < MB:
+		return fmt.Sprintf("%.2fKB", float64(bytes)/KB)
+	case bytes < GB:
+		return fmt.Sprintf("%.2fMB", float64(bytes)/MB)
+	default:
+		return fmt.Sprintf("%.2fGB", float64(bytes)/GB)
+	}
+}
+
+// byteSize is a flag.Value of a memory size, with an optional unit suffix, e.g. 512MiB, 4G
+type byteSize uint64
+
+var byteUnits = []struct {
+	suffix string
+	size   uint64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40},
+	{"B", 1},
+}
+
+func (b *byteSize) String() string {
+	return strconv.FormatUint(uint64(*b), 10)
+}
+
+func (b *byteSize) Set(s string) error {
+	unit := uint64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSuffix(s, u.suffix), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	if n > math.MaxUint64/unit {
+		return fmt.Errorf("memory size %s overflows", s)
+	}
+	*b = byteSize(n * unit)
+	return nil
+}
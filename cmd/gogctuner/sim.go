@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/fangwentong/gogctuner"
+	"github.com/fangwentong/gogctuner/sim"
+)
+
+func runSim(args []string) error {
+	fs := flag.NewFlagSet("sim", flag.ExitOnError)
+	var (
+		tracePath = fs.String("trace", "-", "heap time series in CSV format (time,live_heap,alloc_rate,memory_limit), - for stdin")
+		strategy  = fs.String("strategy", "default", "tuner strategy: default, gogc or memory-limit")
+		opts      sim.Options
+		verbose   = fs.Bool("v", false, "print every simulated GC cycle")
+	)
+	addConfigFlags(fs, &opts.Config)
+	fs.DurationVar(&opts.Step, "step", 10*time.Millisecond, "simulation time step")
+	fs.Float64Var(&opts.MarkRate, "mark-rate", 1<<30, "live heap bytes marked per CPU second")
+	fs.DurationVar(&opts.GCFixedCost, "gc-fixed-cost", 100*time.Microsecond, "fixed CPU cost of a GC cycle")
+	fs.IntVar(&opts.CPUs, "cpus", 1, "number of CPUs available to the process")
+	_ = fs.Parse(args)
+
+	s, err := parseStrategy(*strategy)
+	if err != nil {
+		return err
+	}
+	opts.Strategy = s
+
+	in, err := openInput(*tracePath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	trace, err := sim.ReadTrace(in)
+	if err != nil {
+		return err
+	}
+	res, err := sim.Run(trace, opts)
+	if err != nil {
+		return err
+	}
+	printResult(os.Stdout, res, *verbose)
+	return nil
+}
+
+func addConfigFlags(fs *flag.FlagSet, config *gogctuner.Config) {
+	fs.Float64Var(&config.MaxRAMPercentage, "max-ram-percentage", 0, "gctuner MaxRAMPercentage, range (0, 100]")
+	fs.IntVar(&config.GOGC, "gogc", 0, "gctuner GOGC")
+	fs.BoolVar(&config.IncludeSwap, "include-swap", false, "target the memory plus the swap available to the process")
+	fs.IntVar(&config.MinGOGC, "min-gogc", 0, "gctuner MinGOGC, 50 if zero")
+	fs.Float64Var(&config.EmergencyRAMPercentage, "emergency-ram-percentage", 0, "gctuner EmergencyRAMPercentage, 95 if zero")
+}
+
+func parseStrategy(name string) (gogctuner.Strategy, error) {
+	switch name {
+	case "default":
+		return gogctuner.DefaultStrategy(), nil
+	case "gogc":
+		return gogctuner.GOGCStrategy, nil
+	case "memory-limit":
+		return gogctuner.MemoryLimitStrategy, nil
+	}
+	return nil, fmt.Errorf("unknown strategy %q", name)
+}
+
+// openInput opens the file at path, or stdin if path is "-"
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+func printResult(w io.Writer, res sim.Result, verbose bool) {
+	if verbose {
+		for _, c := range res.Cycles {
+			fmt.Fprintf(w, "gc @%v: heap %s, live %s, goal %s -> GOGC %d, memory limit %s\n",
+				c.Time, formatBytes(c.Heap), formatBytes(c.LiveHeap), formatBytes(c.Goal),
+				c.Decision.GOGC, formatBytes(uint64(c.Decision.MemoryLimit)))
+		}
+	}
+	fmt.Fprintf(w, "duration:   %v\n", res.Duration)
+	fmt.Fprintf(w, "gc count:   %d\n", res.GCCount)
+	fmt.Fprintf(w, "peak heap:  %s\n", formatBytes(res.PeakHeap))
+	fmt.Fprintf(w, "gc cpu:     %v (%.2f%%)\n", res.GCCPU, res.GCCPUFraction*100)
+	fmt.Fprintf(w, "oom events: %d\n", len(res.OOMs))
+	for _, e := range res.OOMs {
+		fmt.Fprintf(w, "  oom @%v: heap %s, limit %s\n", e.Time, formatBytes(e.Heap), formatBytes(e.MemoryLimit))
+	}
+}
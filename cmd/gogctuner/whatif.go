@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/fangwentong/gogctuner/sim"
+)
+
+func runWhatIf(args []string) error {
+	fs := flag.NewFlagSet("whatif", flag.ExitOnError)
+	var (
+		tracePath   = fs.String("gctrace", "-", "log file containing GODEBUG=gctrace=1 output, - for stdin")
+		strategy    = fs.String("strategy", "default", "tuner strategy: default, gogc or memory-limit")
+		memoryLimit byteSize
+		opts        sim.Options
+		verbose     = fs.Bool("v", false, "print the tuner decision of every recorded GC cycle")
+	)
+	fs.Var(&memoryLimit, "memory-limit", "memory limit of the traced process, e.g. 4GiB")
+	addConfigFlags(fs, &opts.Config)
+	_ = fs.Parse(args)
+
+	if memoryLimit == 0 {
+		return errors.New("-memory-limit is required")
+	}
+	s, err := parseStrategy(*strategy)
+	if err != nil {
+		return err
+	}
+	opts.Strategy = s
+
+	in, err := openInput(*tracePath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	cycles, err := sim.ParseGCTrace(in)
+	if err != nil {
+		return err
+	}
+	report, err := sim.WhatIf(cycles, uint64(memoryLimit), opts)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *verbose {
+		for _, c := range report.Cycles {
+			fmt.Fprintf(w, "gc %d @%v: %s->%s->%s, goal %s -> GOGC %d, memory limit %s\n",
+				c.Num, c.Time, formatBytes(c.HeapStart), formatBytes(c.HeapEnd), formatBytes(c.LiveHeap),
+				formatBytes(c.HeapGoal), c.Decision.GOGC, formatBytes(uint64(c.Decision.MemoryLimit)))
+		}
+	}
+	fmt.Fprintf(w, "observed gc count: %d\n", report.ObservedGCCount)
+	printResult(w, report.Result, false)
+	return nil
+}
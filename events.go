@@ -0,0 +1,182 @@
+package gogctuner
+
+import (
+	"math"
+	"time"
+
+	"github.com/fangwentong/gogctuner/internal/cgroup"
+)
+
+const (
+	memoryEventsPollInterval = time.Second
+	defaultOOMCooldown       = time.Minute
+	defaultOOMRecovery       = time.Hour
+)
+
+type (
+	// EventType is the type of a tuner event
+	EventType string
+
+	// Event is emitted by the tuner when something notable happens, see WithEventHandler
+	Event struct {
+		Type EventType
+		Time time.Time
+		// Delta is the increase of the counter since the last poll
+		Delta uint64
+		// Total is the current value of the counter
+		Total uint64
+		// Rollback is the detail of EventRollback, nil for the other events
+		Rollback *RollbackReport
+	}
+
+	// MemoryEvents is the memory cgroup events signal of gctuner, see Config.OOMStepPercentage
+	MemoryEvents struct {
+		// High, Max, OOM and OOMKill are the latest counters of the memory cgroup events
+		High    uint64
+		Max     uint64
+		OOM     uint64
+		OOMKill uint64
+		// Error is the error of the latest reading, e.g. there's no memory cgroup
+		Error string
+		// StepDownCount is the number of times the effective MaxRAMPercentage has been lowered
+		StepDownCount uint64
+		// StepDownPercentage is the percentage points the effective MaxRAMPercentage is lowered by at LastStepDown,
+		// it decays to 0 over Config.OOMRecovery
+		StepDownPercentage float64
+		// LastStepDown is the time of the last step down
+		LastStepDown time.Time
+	}
+)
+
+const (
+	// EventMemoryHigh is emitted when the processes in the cgroup are throttled because of memory.high
+	EventMemoryHigh EventType = "memory_high"
+	// EventMemoryMax is emitted when the memory usage of the cgroup was about to go over the limit
+	EventMemoryMax EventType = "memory_max"
+	// EventOOM is emitted when an allocation in the cgroup failed because the memory limit was hit
+	EventOOM EventType = "oom"
+	// EventOOMKill is emitted when a process in the cgroup is killed by the OOM killer
+	EventOOMKill EventType = "oom_kill"
+)
+
+// watchMemoryEvents polls the memory cgroup event counters, it returns if the counters are unavailable
+func (a *adaptiveGCHandler) watchMemoryEvents() {
+	prev, err := a.rt.ReadMemoryEvents()
+	a.updateMemoryEvents(prev, err)
+	if err != nil {
+		return
+	}
+	t := a.rt.NewTicker(memoryEventsPollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C():
+		case <-a.done:
+			return
+		}
+		events, err := a.rt.ReadMemoryEvents()
+		a.updateMemoryEvents(events, err)
+		if err != nil {
+			continue
+		}
+		if a.handleMemoryEvents(prev, events) {
+			a.notify()
+		}
+		prev = events
+	}
+}
+
+func (a *adaptiveGCHandler) updateMemoryEvents(events cgroup.MemoryEvents, err error) {
+	a.updateStatus(func(s *Status) {
+		e := &s.MemoryEvents
+		if err != nil {
+			e.Error = err.Error()
+			return
+		}
+		e.High, e.Max, e.OOM, e.OOMKill, e.Error = events.High, events.Max, events.OOM, events.OOMKill, ""
+	})
+}
+
+// handleMemoryEvents emits the events for the increased counters,
+// and reports whether the effective MaxRAMPercentage is lowered
+func (a *adaptiveGCHandler) handleMemoryEvents(prev, cur cgroup.MemoryEvents) bool {
+	now := a.rt.Now()
+	var stepDown bool
+	for _, c := range []struct {
+		typ       EventType
+		prev, cur uint64
+	}{
+		{EventMemoryHigh, prev.High, cur.High},
+		{EventMemoryMax, prev.Max, cur.Max},
+		{EventOOM, prev.OOM, cur.OOM},
+		{EventOOMKill, prev.OOMKill, cur.OOMKill},
+	} {
+		if c.cur <= c.prev { // the counter is reset if the cgroup is recreated
+			continue
+		}
+		a.logger.Logf("gctuner: memory cgroup event %s increased by %d to %d", c.typ, c.cur-c.prev, c.cur)
+		a.emit(Event{Type: c.typ, Time: now, Delta: c.cur - c.prev, Total: c.cur})
+		// memory.high throttling and the max counter are part of the normal page cache reclaim at the limit
+		stepDown = stepDown || c.typ == EventOOM || c.typ == EventOOMKill
+	}
+	return stepDown && a.stepDown(now)
+}
+
+// stepDown lowers the effective MaxRAMPercentage by OOMStepPercentage unless it's in the cooldown
+func (a *adaptiveGCHandler) stepDown(now time.Time) bool {
+	config, _ := a.prevConfig.Load().(Config)
+	if config.OOMStepPercentage <= 0 || config.MaxRAMPercentage <= 0 {
+		return false
+	}
+	cooldown := config.OOMCooldown
+	if cooldown == 0 {
+		cooldown = defaultOOMCooldown
+	}
+	var lowered bool
+	a.updateStatus(func(s *Status) {
+		e := &s.MemoryEvents
+		maxStep := config.MaxRAMPercentage / 2
+		step := oomStepDown(e, now, config)
+		if (!e.LastStepDown.IsZero() && now.Sub(e.LastStepDown) < cooldown) || step >= maxStep {
+			return
+		}
+		e.StepDownPercentage = math.Min(step+config.OOMStepPercentage, maxStep)
+		e.StepDownCount++
+		e.LastStepDown = now
+		lowered = true
+		a.logger.Logf("gctuner: lower max_ram_percentage by %.2f to %.2f due to memory cgroup events",
+			e.StepDownPercentage, config.MaxRAMPercentage-e.StepDownPercentage)
+	})
+	return lowered
+}
+
+// applyStepDown lowers MaxRAMPercentage by the decaying steps of the memory cgroup events
+func (a *adaptiveGCHandler) applyStepDown(config Config) Config {
+	if config.OOMStepPercentage <= 0 || config.MaxRAMPercentage <= 0 {
+		return config
+	}
+	now := a.rt.Now()
+	a.statusMu.Lock()
+	step := oomStepDown(&a.status.MemoryEvents, now, config)
+	a.statusMu.Unlock()
+	config.MaxRAMPercentage -= math.Min(step, config.MaxRAMPercentage/2)
+	return config
+}
+
+// oomStepDown returns the step down of the memory cgroup events decayed over Config.OOMRecovery
+func oomStepDown(e *MemoryEvents, now time.Time, config Config) float64 {
+	recovery := config.OOMRecovery
+	if recovery == 0 {
+		recovery = defaultOOMRecovery
+	}
+	return decayPenalty(e.StepDownPercentage, e.LastStepDown, now, recovery)
+}
+
+func (a *adaptiveGCHandler) emit(e Event) {
+	if a.eventHandler == nil {
+		return
+	}
+	a.withRecover(func() {
+		a.eventHandler(e)
+	})()
+}
@@ -0,0 +1,99 @@
+package gogctuner
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestMemoryEventsStepDown(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1 << 30)
+	config := Config{MaxRAMPercentage: 80, OOMStepPercentage: 15, OOMCooldown: time.Minute, OOMRecovery: 10 * time.Minute}
+	h, _ := newTestHandler(rt, staticConfigurator{config: config})
+	var events []Event
+	h.eventHandler = func(e Event) {
+		events = append(events, e)
+	}
+	h.checkAndSetNextGCConfig()
+
+	var prev gctunertest.MemoryEvents
+	f := func(cur gctunertest.MemoryEvents, lowered bool, maxRAMPercentage float64) {
+		t.Helper()
+		if h.handleMemoryEvents(prev, cur) != lowered {
+			t.Fatalf("unexpected step down for %+v, want %v", cur, lowered)
+		}
+		prev = cur
+		h.checkAndSetNextGCConfig()
+		if p := h.Status().AppliedConfig.MaxRAMPercentage; math.Abs(p-maxRAMPercentage) > 1e-9 {
+			t.Fatalf("unexpected applied MaxRAMPercentage, got: %.2f, want %.2f", p, maxRAMPercentage)
+		}
+	}
+
+	f(gctunertest.MemoryEvents{High: 3}, false, 80)
+	f(gctunertest.MemoryEvents{High: 3, OOMKill: 1}, true, 65)
+	f(gctunertest.MemoryEvents{High: 3, OOM: 1, OOMKill: 2}, false, 65) // cooldown
+	rt.Advance(time.Minute)
+	f(gctunertest.MemoryEvents{High: 3, OOM: 2, OOMKill: 2}, true, 51.5) // 15 decayed to 13.5 plus a step
+	rt.Advance(time.Minute)
+	f(gctunertest.MemoryEvents{High: 3, OOM: 3, OOMKill: 2}, true, 40) // lowered by half at most
+	rt.Advance(time.Minute)
+	f(gctunertest.MemoryEvents{High: 3, Max: 7, OOM: 3, OOMKill: 2}, false, 44) // recovering
+	rt.Advance(10 * time.Minute)
+	f(gctunertest.MemoryEvents{High: 3, Max: 7, OOM: 3, OOMKill: 2}, false, 80)
+
+	if len(events) != 7 {
+		t.Fatalf("unexpected number of events, got: %d, want 7", len(events))
+	}
+	if e := events[2]; e.Type != EventOOM || e.Delta != 1 || e.Total != 1 || !e.Time.Equal(gctunertest.Epoch) {
+		t.Fatalf("unexpected event, got: %+v", e)
+	}
+	if e := events[3]; e.Type != EventOOMKill || e.Delta != 1 || e.Total != 2 {
+		t.Fatalf("unexpected event, got: %+v", e)
+	}
+	if n := h.Status().MemoryEvents.StepDownCount; n != 3 {
+		t.Fatalf("unexpected step down count, got: %d, want 3", n)
+	}
+}
+
+func TestMemoryEventsMaxOnly(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1 << 30)
+	h, _ := newTestHandler(rt, staticConfigurator{config: Config{MaxRAMPercentage: 80, OOMStepPercentage: 15}})
+	h.checkAndSetNextGCConfig()
+
+	// the max counter increases on the page cache reclaim at the limit
+	var prev gctunertest.MemoryEvents
+	for i := uint64(1); i <= 10; i++ {
+		cur := gctunertest.MemoryEvents{High: i, Max: 100 * i}
+		if h.handleMemoryEvents(prev, cur) {
+			t.Fatalf("unexpected step down for %+v", cur)
+		}
+		prev = cur
+		rt.Advance(time.Minute)
+	}
+	h.checkAndSetNextGCConfig()
+	if s := h.Status(); s.AppliedConfig.MaxRAMPercentage != 80 || s.MemoryEvents.StepDownCount != 0 {
+		t.Fatalf("unexpected applied MaxRAMPercentage, got: %.2f (%+v), want 80", s.AppliedConfig.MaxRAMPercentage, s.MemoryEvents)
+	}
+}
+
+func TestMemoryEventsDisabled(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	h, _ := newTestHandler(rt, staticConfigurator{config: Config{MaxRAMPercentage: 80}})
+	h.checkAndSetNextGCConfig()
+	if h.handleMemoryEvents(gctunertest.MemoryEvents{}, gctunertest.MemoryEvents{OOMKill: 1}) {
+		t.Fatalf("unexpected step down without OOMStepPercentage")
+	}
+}
+
+func TestWatchMemoryEventsUnavailable(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	h, _ := newTestHandler(rt, staticConfigurator{})
+	h.watchMemoryEvents() // returns without the memory cgroup
+	if h.Status().MemoryEvents.Error == "" {
+		t.Fatalf("expecting the error in the status")
+	}
+}
@@ -3,7 +3,13 @@
 
 package gogctuner
 
+import (
+	"github.com/fangwentong/gogctuner/internal/gcruntime"
+)
+
+var defaultStrategy = GOGCStrategy
+
 // setGCParameter set GC parameters
-func setGCParameter(oldConfig, newConfig Config, logger Logger) {
-	adjustGOGCByMemoryLimit(oldConfig, newConfig, logger)
+func setGCParameter(oldConfig, newConfig Config, rt gcruntime.Runtime, logger Logger, sameCycle bool) {
+	adjustGOGCByMemoryLimit(oldConfig, newConfig, rt, logger, sameCycle)
 }
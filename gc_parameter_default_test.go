@@ -0,0 +1,65 @@
+//go:build !go1.19
+// +build !go1.19
+
+package gogctuner
+
+import (
+	"testing"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestSetGCParameterTransitions(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(10000 << 20)
+	configurator := NewGcConfigurator()
+	h, _ := newTestHandler(rt, configurator)
+
+	f := func(config Config, liveHeap uint64, wantGOGC int) {
+		t.Helper()
+		rt.SetLiveHeap(liveHeap)
+		configurator.SetConfig(config)
+		h.checkAndSetNextGCConfig()
+		if rt.GCPercent() != wantGOGC {
+			t.Fatalf("unexpected GOGC for %+v, got: %d, want %d", config, rt.GCPercent(), wantGOGC)
+		}
+	}
+	f(Config{MaxRAMPercentage: 80}, 1000<<20, 700)
+	f(Config{MaxRAMPercentage: 80}, 3000<<20, 166)
+	f(Config{MaxRAMPercentage: 80, GOGC: 100}, 1000<<20, 100)
+	// reset to the defaults
+	f(Config{}, 1000<<20, 100)
+	// reset to the environment
+	rt.Setenv("GOGC", "off")
+	f(Config{GOGC: 200}, 1000<<20, 200)
+	f(Config{}, 1000<<20, -1)
+
+	// target memory+swap
+	rt.SetDetectedSwapLimit(2000 << 20)
+	f(Config{MaxRAMPercentage: 80, IncludeSwap: true}, 3000<<20, 220)
+}
+
+func TestSmoothingPerGCCycle(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(10000 << 20)
+	rt.SetLiveHeap(1000 << 20)
+	h, logger := newTestHandler(rt, staticConfigurator{config: Config{MaxRAMPercentage: 80, GOGCMaxStepUp: 50}})
+
+	f := func(gc bool, wantGOGC, wantLogs int) {
+		t.Helper()
+		if gc {
+			rt.TriggerGC()
+		}
+		h.checkAndSetNextGCConfig()
+		if rt.GCPercent() != wantGOGC || len(logger.Logs()) != wantLogs {
+			t.Fatalf("unexpected GOGC, got: %d (%d logs), want %d (%d logs)", rt.GCPercent(), len(logger.Logs()), wantGOGC, wantLogs)
+		}
+	}
+	f(false, 150, 1) // the first evaluation
+	f(false, 150, 1) // the periodic re-evaluation
+	f(true, 200, 2)
+	f(false, 200, 2) // the same GC cycle seen twice
+	rt.Advance(periodicCheckInterval)
+	f(false, 200, 2)
+	f(true, 250, 3)
+}
@@ -5,56 +5,61 @@ package gogctuner
 
 import (
 	"math"
-	"os"
 	"reflect"
-	"runtime/debug"
+
+	"github.com/fangwentong/gogctuner/internal/gcruntime"
 )
 
-// setGCParameter sets the GC parameters
-func setGCParameter(oldConfig, newConfig Config, logger Logger) {
-	if reflect.DeepEqual(oldConfig, newConfig) {
-		// The config has no change
-		return
-	}
+var defaultStrategy = MemoryLimitStrategy
+
+// setGCParameter sets the GC parameters, GOGC is not smoothed with the memory limit, so sameCycle is unused
+func setGCParameter(oldConfig, newConfig Config, rt gcruntime.Runtime, logger Logger, _ bool) {
+	unchanged := reflect.DeepEqual(oldConfig, newConfig)
 	if newConfig.MaxRAMPercentage > 0 {
-		memLimit, err := getMemoryLimit()
+		// The memory limit depends on the live heap beyond MaxRAMPercentage, see decideMemoryLimit
+		memLimit, err := getMemoryLimit(rt, newConfig)
 		if err != nil {
-			logger.Errorf("gctuner: failed to adjust GC, get memory limit err: %v", err.Error())
+			if !unchanged {
+				logger.Errorf("gctuner: failed to adjust GC, get memory limit err: %v", err.Error())
+			}
 			return
 		}
-		limit := int64(newConfig.MaxRAMPercentage / 100.0 * float64(memLimit))
-		gogc := newConfig.GOGC
-		if gogc == 0 { // gogc is not set
-			gogc = -1 // Disable GC unless the memory limit is reached
+		d := MemoryLimitStrategy.Decide(newConfig, StrategyInput{MemoryLimit: memLimit, LiveHeap: rt.LiveDatasetSize()})
+		if unchanged && rt.SetMemoryLimit(-1) == d.MemoryLimit {
+			return
 		}
-		debug.SetGCPercent(gogc)
-		debug.SetMemoryLimit(limit)
-		logger.Logf("gctuner: set memory limit %v", printMemorySize(uint64(limit)))
+		rt.SetGCPercent(d.GOGC)
+		rt.SetMemoryLimit(d.MemoryLimit)
+		logger.Logf("gctuner: set memory limit %v", printMemorySize(uint64(d.MemoryLimit)))
+		return
+	}
+	if unchanged {
+		// The config has no change
 		return
 	}
 
 	if oldConfig.MaxRAMPercentage != 0 {
 		// The config has been changed, reset the memory limit and GOGC
-		defaultMemLimit := readGOMEMLIMIT(logger)
+		defaultMemLimit := readGOMEMLIMIT(rt, logger)
 		if defaultMemLimit != 0 {
 			logger.Logf("gctuner: reset memory limit %v", printMemorySize(uint64(defaultMemLimit)))
-			debug.SetMemoryLimit(defaultMemLimit)
+			rt.SetMemoryLimit(defaultMemLimit)
 		}
 		// reset GOGC
-		setGOGCOrDefault(newConfig.GOGC, logger)
+		setGOGCOrDefault(newConfig.GOGC, rt, logger)
 		return
 	}
 
 	// Set the GOGC value
 	if newConfig.GOGC != oldConfig.GOGC {
-		setGOGCOrDefault(newConfig.GOGC, logger)
+		setGOGCOrDefault(newConfig.GOGC, rt, logger)
 	}
 }
 
 // readGOMEMLIMIT reads the GOMEMLIMIT value
 // Copied from runtime.readGOMEMLIMIT
-func readGOMEMLIMIT(logger Logger) int64 {
-	p := os.Getenv("GOMEMLIMIT")
+func readGOMEMLIMIT(rt gcruntime.Runtime, logger Logger) int64 {
+	p := rt.Getenv("GOMEMLIMIT")
 	if p == "" || p == "off" {
 		return math.MaxInt64
 	}
@@ -0,0 +1,95 @@
+//go:build go1.19
+// +build go1.19
+
+package gogctuner
+
+import (
+	"math"
+	"testing"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestSetGCParameterTransitions(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1000 << 20)
+	configurator := NewGcConfigurator()
+	h, _ := newTestHandler(rt, configurator)
+
+	f := func(config Config, wantGOGC int, wantLimit int64) {
+		t.Helper()
+		configurator.SetConfig(config)
+		h.checkAndSetNextGCConfig()
+		if rt.GCPercent() != wantGOGC || rt.GCMemoryLimit() != wantLimit {
+			t.Fatalf("unexpected GC parameters for %+v, got: GOGC %d, limit %d, want GOGC %d, limit %d",
+				config, rt.GCPercent(), rt.GCMemoryLimit(), wantGOGC, wantLimit)
+		}
+	}
+	f(Config{MaxRAMPercentage: 80}, -1, 800<<20)
+	f(Config{MaxRAMPercentage: 50, GOGC: 200}, 200, 500<<20)
+	// reset to the defaults
+	f(Config{}, 100, math.MaxInt64)
+	f(Config{GOGC: 300}, 300, math.MaxInt64)
+	f(Config{MaxRAMPercentage: 50}, -1, 500<<20)
+
+	// reset to the environment
+	rt.Setenv("GOGC", "off")
+	rt.Setenv("GOMEMLIMIT", "100MiB")
+	f(Config{}, -1, 100<<20)
+	rt.Setenv("GOGC", "150")
+	f(Config{MaxRAMPercentage: 50}, -1, 500<<20)
+	f(Config{}, 150, 100<<20)
+
+	// target memory+swap
+	rt.SetDetectedSwapLimit(500 << 20)
+	f(Config{MaxRAMPercentage: 80, IncludeSwap: true}, -1, 1200<<20)
+	f(Config{MaxRAMPercentage: 80}, -1, 800<<20)
+
+	// the memory limit changes
+	rt.SetDetectedMemoryLimit(2000 << 20)
+	f(Config{MaxRAMPercentage: 80}, -1, 1600<<20)
+	tiers := []MemoryTier{{MaxLimit: 1 << 30, MaxRAMPercentage: 70}, {MaxRAMPercentage: 90}}
+	f(Config{MaxRAMPercentage: 80, Tiers: tiers}, -1, 1800<<20)
+	rt.SetDetectedMemoryLimit(1000 << 20)
+	f(Config{MaxRAMPercentage: 80, Tiers: tiers}, -1, 700<<20)
+}
+
+func TestSetGCParameterNoMemoryLimit(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	h, logger := newTestHandler(rt, staticConfigurator{config: Config{MaxRAMPercentage: 80}})
+	h.checkAndSetNextGCConfig()
+	if len(logger.Errors()) != 1 || rt.GCPercent() != 100 || rt.GCMemoryLimit() != math.MaxInt64 {
+		t.Fatalf("expecting GC parameters to be kept, errors: %q, GOGC: %d, limit: %d",
+			logger.Errors(), rt.GCPercent(), rt.GCMemoryLimit())
+	}
+}
+
+func TestMemoryLimitBounds(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1000 << 20)
+	configurator := &silentConfigurator{}
+	h, _ := newTestHandler(rt, configurator)
+
+	f := func(config Config, liveHeap uint64, wantLimit int64) {
+		t.Helper()
+		rt.SetLiveHeap(liveHeap)
+		configurator.set(config)
+		h.checkAndSetNextGCConfig()
+		if rt.GCMemoryLimit() != wantLimit {
+			t.Fatalf("unexpected memory limit for %+v with the live heap %d, got: %d, want %d",
+				config, liveHeap, rt.GCMemoryLimit(), wantLimit)
+		}
+	}
+	f(Config{MaxRAMPercentage: 80}, 400<<20, 800<<20)
+	// the live heap leaves no room for MinGOGC, up to EmergencyRAMPercentage
+	f(Config{MaxRAMPercentage: 80}, 600<<20, 900<<20)
+	f(Config{MaxRAMPercentage: 80}, 800<<20, 950<<20)
+	f(Config{MaxRAMPercentage: 80, EmergencyRAMPercentage: 90}, 800<<20, 900<<20)
+	f(Config{MaxRAMPercentage: 80, MinGOGC: 10}, 600<<20, 800<<20)
+	f(Config{MaxRAMPercentage: 80}, 400<<20, 800<<20)
+
+	// MinHeapSize is the floor of the memory limit below EmergencyRAMPercentage
+	f(Config{MaxRAMPercentage: 1}, 0, 10<<20)
+	f(Config{MaxRAMPercentage: 1, MinHeapSize: 100 << 20}, 0, 100<<20)
+	f(Config{MaxRAMPercentage: 1, MinHeapSize: 990 << 20}, 0, 950<<20)
+}
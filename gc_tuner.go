@@ -8,6 +8,9 @@ import (
 	"runtime/debug"
 	"sync"
 	"sync/atomic"
+	"time"
+
+	"github.com/fangwentong/gogctuner/internal/gcruntime"
 )
 
 var (
@@ -30,6 +33,150 @@ type (
 
 		// MaxRAMPercentage is the maximum memory usage, range (0, 100]
 		MaxRAMPercentage float64 `json:"max_ram_percentage,omitempty" yaml:"max_ram_percentage,omitempty"`
+
+		// IncludeSwap makes MaxRAMPercentage target the memory plus the swap available to the process,
+		// instead of the memory only. The swap is limited by the cgroup swap limit
+		// (memory.swap.max in cgroup v2, memory.memsw.limit_in_bytes in cgroup v1) and the host swap size.
+		IncludeSwap bool `json:"include_swap,omitempty" yaml:"include_swap,omitempty"`
+
+		// PressureThreshold enables the memory pressure signal if specified, range (0, 100].
+		// When the "some avg10" of the memory pressure stall information (memory.pressure of the cgroup,
+		// or /proc/pressure/memory without cgroup v2) exceeds this percentage, gctuner tightens the GC temporarily:
+		// MaxRAMPercentage is lowered by PressureStepPercentage and GOGC is halved.
+		// The GC is relaxed once "some avg10" drops below half of the threshold.
+		PressureThreshold float64 `json:"pressure_threshold,omitempty" yaml:"pressure_threshold,omitempty"`
+
+		// PressureStepPercentage is the percentage points MaxRAMPercentage is lowered by under memory pressure,
+		// it's 10 by default, and MaxRAMPercentage is lowered by half at most.
+		PressureStepPercentage float64 `json:"pressure_step_percentage,omitempty" yaml:"pressure_step_percentage,omitempty"`
+
+		// OOMStepPercentage enables the automatic response to the memory cgroup events if specified.
+		// When the oom or oom_kill counter of memory.events (the oom_kill counter of memory.oom_control
+		// in cgroup v1) increases, e.g. a sidecar in the same cgroup is OOM-killed, the effective MaxRAMPercentage
+		// is lowered by this percentage points, at most once per OOMCooldown. The max counter (memory.failcnt
+		// in cgroup v1) is only emitted as an event, as it increases on the page cache reclaim at the limit too.
+		// The steps add up until MaxRAMPercentage is lowered by half, and recover over OOMRecovery,
+		// see Status.MemoryEvents.
+		OOMStepPercentage float64 `json:"oom_step_percentage,omitempty" yaml:"oom_step_percentage,omitempty"`
+
+		// OOMCooldown is the minimum interval between two steps of OOMStepPercentage, 1 minute by default.
+		OOMCooldown time.Duration `json:"oom_cooldown,omitempty" yaml:"oom_cooldown,omitempty"`
+
+		// OOMRecovery is the duration the steps of OOMStepPercentage decay linearly to 0 over
+		// since the last step, 1 hour by default.
+		OOMRecovery time.Duration `json:"oom_recovery,omitempty" yaml:"oom_recovery,omitempty"`
+
+		// AccountNonGoMemory makes MaxRAMPercentage cover the memory not managed by the Go runtime,
+		// e.g. cgo allocations and mmap'd files. The non-Go memory is measured as the RSS of the process minus
+		// the memory mapped by the Go runtime (/memory/classes/total:bytes - /memory/classes/heap/released:bytes),
+		// sampled on the periodic re-evaluations, and the smoothed value is subtracted from the budget of the Go runtime,
+		// see Status.NonGoMemory.
+		// It requires go1.16 and linux.
+		AccountNonGoMemory bool `json:"account_non_go_memory,omitempty" yaml:"account_non_go_memory,omitempty"`
+
+		// ElevatedWatermark and CriticalWatermark are the percentages of the live heap plus the off-heap memory
+		// in the effective limit (MaxRAMPercentage of the memory limit), where the heap pressure level becomes
+		// PressureElevated and PressureCritical, they're 70 and 90 by default, see OnPressure.
+		ElevatedWatermark float64 `json:"elevated_watermark,omitempty" yaml:"elevated_watermark,omitempty"`
+		CriticalWatermark float64 `json:"critical_watermark,omitempty" yaml:"critical_watermark,omitempty"`
+
+		// IdleReturnInterval enables returning the free memory to the OS when the process is idle if specified,
+		// it's the minimum interval between two returns. The process is idle if the allocation rate
+		// (/gc/heap/allocs:bytes) is below IdleAllocRate, the memory is returned by debug.FreeOSMemory.
+		// It requires go1.16, see Status.IdleReturn.
+		IdleReturnInterval time.Duration `json:"idle_return_interval,omitempty" yaml:"idle_return_interval,omitempty"`
+
+		// IdleAllocRate is the allocation rate in bytes per second below which the process is idle, 1MiB/s by default.
+		IdleAllocRate uint64 `json:"idle_alloc_rate,omitempty" yaml:"idle_alloc_rate,omitempty"`
+
+		// IdleReturnCPUPercentage is the budget of the time spent in returning memory to the OS, range (0, 100],
+		// it's 1 by default, i.e. a return taking 100ms is followed by 10s at least before the next one.
+		IdleReturnCPUPercentage float64 `json:"idle_return_cpu_percentage,omitempty" yaml:"idle_return_cpu_percentage,omitempty"`
+
+		// MaxGCInterval forces a GC cycle if no GC cycle has happened within it, if specified.
+		// With GOGC=-1 and the soft memory limit, a lightly loaded process may go a long time without GC,
+		// so the finalizers, the weak caches and the GC hook of gctuner stop running.
+		// It's like the 2-minute forced GC of the runtime, which is disabled by GOGC=-1, see Status.ForcedGCs.
+		MaxGCInterval time.Duration `json:"max_gc_interval,omitempty" yaml:"max_gc_interval,omitempty"`
+
+		// Predictive enables the predictive mode: the live heap is tracked by Holt's linear smoothing after every GC
+		// cycle, and the allocation rate and the GC interval by moving averages. The forecast growth of the live heap
+		// by the next GC cycle, or the forecast overshoot of the heap at the next GC cycle (the live heap plus
+		// the allocation rate times the GC interval) over the target if larger, is subtracted from the budget,
+		// so that a fast-growing heap doesn't overshoot the target before the next GC cycle, see Status.Forecast.
+		Predictive bool `json:"predictive,omitempty" yaml:"predictive,omitempty"`
+
+		// GOGCMinChangePercentage, GOGCMaxStepUp and GOGCMaxStepDown smooth the GOGC adjustments of MaxRAMPercentage
+		// before go1.19, which are made after every GC cycle. A new GOGC is not applied if it differs from the current
+		// one by less than GOGCMinChangePercentage percent of the current one, and GOGC is raised by GOGCMaxStepUp and
+		// lowered by GOGCMaxStepDown at most per GC cycle, zero means no limit. The re-evaluations without
+		// a new GC cycle, e.g. the periodic ones, keep the current GOGC.
+		// The limits on lowering don't apply when the memory is tight, i.e. the live heap leaves no room for
+		// the minimum GOGC under MaxRAMPercentage, so a small GOGCMaxStepUp drops fast and rises slowly.
+		GOGCMinChangePercentage float64 `json:"gogc_min_change_percentage,omitempty" yaml:"gogc_min_change_percentage,omitempty"`
+		GOGCMaxStepUp           int     `json:"gogc_max_step_up,omitempty" yaml:"gogc_max_step_up,omitempty"`
+		GOGCMaxStepDown         int     `json:"gogc_max_step_down,omitempty" yaml:"gogc_max_step_down,omitempty"`
+
+		// MinGOGC is the minimum GOGC set by gctuner, 50 by default, it's the floor of the GOGC adjusted
+		// for MaxRAMPercentage and halved under memory pressure. In go1.19 and above, the soft memory limit
+		// is raised to leave the live heap the room of MinGOGC, up to EmergencyRAMPercentage of the memory limit.
+		MinGOGC int `json:"min_gogc,omitempty" yaml:"min_gogc,omitempty"`
+
+		// EmergencyRAMPercentage is the ceiling of the memory usage when the live heap already exceeds
+		// MaxRAMPercentage, range (0, 100], 95 by default. Before go1.19, GOGC is lowered to keep the heap
+		// below it, down to MinGOGC. In go1.19 and above, it's the ceiling the soft memory limit is raised to
+		// for MinGOGC and MinHeapSize.
+		EmergencyRAMPercentage float64 `json:"emergency_ram_percentage,omitempty" yaml:"emergency_ram_percentage,omitempty"`
+
+		// MinHeapSize is the minimum heap size in bytes the GC parameters are computed for, 4MiB by default,
+		// so that a tiny live heap or a large non-Go memory doesn't make the GC run continuously.
+		// In go1.19 and above, it's the floor of the soft memory limit below EmergencyRAMPercentage.
+		MinHeapSize uint64 `json:"min_heap_size,omitempty" yaml:"min_heap_size,omitempty"`
+
+		// RampDuration and RampCycles make the changes of MaxRAMPercentage and GOGC gradual if specified:
+		// the applied values move toward the new ones linearly over RampDuration, or over RampCycles GC cycles,
+		// whichever completes first. E.g. lowering MaxRAMPercentage below the current heap at once causes a GC storm.
+		// The transition is completed at once if the memory usage exceeds EmergencyRAMPercentage of the memory limit
+		// and it's tightening, or held if it's loosening, see Status.Ramp.
+		RampDuration time.Duration `json:"ramp_duration,omitempty" yaml:"ramp_duration,omitempty"`
+		RampCycles   int           `json:"ramp_cycles,omitempty" yaml:"ramp_cycles,omitempty"`
+
+		// RollbackWindow enables the regression guard of new configs if specified. After a config change,
+		// the GC overhead is measured over RollbackWindow and compared with the last window of the previous config.
+		// If the GC CPU fraction (/cpu/classes/gc/total:cpu-seconds, go1.20) grows by more than
+		// RollbackGCCPUPercentage percent, or the p99 GC pause (/gc/pauses:seconds) grows by more than
+		// RollbackPausePercentage percent, the previous config is restored until the config changes again,
+		// and EventRollback is emitted. Both percentages are 50 by default, and the regressions below 1% of
+		// the CPU time or 1ms of pause are ignored, see Status.Rollback.
+		RollbackWindow          time.Duration `json:"rollback_window,omitempty" yaml:"rollback_window,omitempty"`
+		RollbackGCCPUPercentage float64       `json:"rollback_gc_cpu_percentage,omitempty" yaml:"rollback_gc_cpu_percentage,omitempty"`
+		RollbackPausePercentage float64       `json:"rollback_pause_percentage,omitempty" yaml:"rollback_pause_percentage,omitempty"`
+
+		// RestartStepPercentage is the percentage points MaxRAMPercentage is lowered by on startup if the previous
+		// instance is considered OOM-killed, 10 by default, it requires WithStateFile. The penalties of consecutive
+		// OOM kills add up to RestartMaxStepPercentage, or half of MaxRAMPercentage at most, and the penalty decays
+		// linearly to 0 over RestartDecay, 1 hour by default, see Status.Restart.
+		RestartStepPercentage    float64       `json:"restart_step_percentage,omitempty" yaml:"restart_step_percentage,omitempty"`
+		RestartMaxStepPercentage float64       `json:"restart_max_step_percentage,omitempty" yaml:"restart_max_step_percentage,omitempty"`
+		RestartDecay             time.Duration `json:"restart_decay,omitempty" yaml:"restart_decay,omitempty"`
+		// RestartUncleanExit also considers the previous instance OOM-killed if it exited without Shutdown with
+		// the peak usage above EmergencyRAMPercentage of the memory limit. It detects the OOM kills that recreate
+		// the memory cgroup and reset its oom_kill counter, e.g. a restart of an OOMKilled Kubernetes container.
+		// Shutdown must be called on every graceful exit, otherwise the exits near the limit are counted as OOM kills.
+		RestartUncleanExit bool `json:"restart_unclean_exit,omitempty" yaml:"restart_unclean_exit,omitempty"`
+
+		// Tiers selects MaxRAMPercentage by the detected memory limit if specified, the first tier with MaxLimit
+		// above the memory limit is selected, and MaxRAMPercentage is kept if none matches. E.g. 70% below 1GiB,
+		// 85% below 8GiB, and 92% otherwise. The tiers are re-evaluated on every re-evaluation of the GC parameters,
+		// so that a change of the memory limit is followed. The tiers of the overrides are ignored, see Status.Tier.
+		Tiers []MemoryTier `json:"tiers,omitempty" yaml:"tiers,omitempty"`
+
+		// RequestPercentage enables the dual targets of the memory request and the memory limit if specified,
+		// range (0, 100], it requires WithMemoryRequest. E.g. in a Kubernetes Burstable pod, the request is guaranteed
+		// by the scheduler while the limit is the OOM point. The tuner targets RequestPercentage of the request
+		// in the steady state, and expands the target toward MaxRAMPercentage of the limit only if the live heap
+		// needs it, i.e. the live heap with MinGOGC exceeds the request target, see Status.MemoryRequest.
+		RequestPercentage float64 `json:"request_percentage,omitempty" yaml:"request_percentage,omitempty"`
 	}
 
 	// Configurator is an interface for configuration management
@@ -57,8 +204,15 @@ func EnableGCTuner(options ...Option) error {
 }
 
 type opts struct {
-	logger       Logger
-	configurator Configurator
+	logger          Logger
+	configurator    Configurator
+	runtime         gcruntime.Runtime
+	eventHandler    func(Event)
+	trigger         Trigger
+	pollInterval    time.Duration
+	stateFile       string
+	requestProvider MemoryRequestProvider
+	overrideTTL     time.Duration
 }
 
 type Option func(*opts)
@@ -84,6 +238,36 @@ func WithLogger(logger Logger) Option {
 	}
 }
 
+// WithRuntime replaces the Go runtime and the environment seen by gctuner, it's intended for tests,
+// see package gctunertest for a fake runtime.
+func WithRuntime(rt gcruntime.Runtime) Option {
+	return func(o *opts) {
+		o.runtime = rt
+	}
+}
+
+// WithTrigger sets the source of the re-evaluations of the GC parameters, it's TriggerGCHook by default.
+func WithTrigger(trigger Trigger) Option {
+	return func(o *opts) {
+		o.trigger = trigger
+	}
+}
+
+// WithPollInterval sets the polling interval of TriggerTicker, it's 100ms by default.
+func WithPollInterval(d time.Duration) Option {
+	return func(o *opts) {
+		o.pollInterval = d
+	}
+}
+
+// WithEventHandler sets the handler of the tuner events, e.g. the OOM kills in the memory cgroup.
+// The handler is called synchronously by the tuner, it should not block.
+func WithEventHandler(handler func(Event)) Option {
+	return func(o *opts) {
+		o.eventHandler = handler
+	}
+}
+
 func (c *Config) CheckValid() error {
 	if c == nil {
 		return nil
@@ -91,6 +275,91 @@ func (c *Config) CheckValid() error {
 	if c.MaxRAMPercentage < 0 || c.MaxRAMPercentage > 100 {
 		return fmt.Errorf("invalid max_ram_percentage value: %f, expected range (0, 100]", c.MaxRAMPercentage)
 	}
+	if c.PressureThreshold < 0 || c.PressureThreshold > 100 {
+		return fmt.Errorf("invalid pressure_threshold value: %f, expected range (0, 100]", c.PressureThreshold)
+	}
+	if c.PressureStepPercentage < 0 || c.PressureStepPercentage > 100 {
+		return fmt.Errorf("invalid pressure_step_percentage value: %f, expected range (0, 100]", c.PressureStepPercentage)
+	}
+	if c.OOMStepPercentage < 0 || c.OOMStepPercentage > 100 {
+		return fmt.Errorf("invalid oom_step_percentage value: %f, expected range (0, 100]", c.OOMStepPercentage)
+	}
+	if c.ElevatedWatermark < 0 || c.ElevatedWatermark > 100 {
+		return fmt.Errorf("invalid elevated_watermark value: %f, expected range (0, 100]", c.ElevatedWatermark)
+	}
+	if c.CriticalWatermark < 0 || c.CriticalWatermark > 100 {
+		return fmt.Errorf("invalid critical_watermark value: %f, expected range (0, 100]", c.CriticalWatermark)
+	}
+	if elevated, critical := c.watermarks(); elevated > critical {
+		return fmt.Errorf("invalid watermarks, elevated_watermark %f is above critical_watermark %f", elevated, critical)
+	}
+	if c.IdleReturnInterval < 0 {
+		return fmt.Errorf("invalid idle_return_interval value: %v, expected non-negative", c.IdleReturnInterval)
+	}
+	if c.IdleReturnCPUPercentage < 0 || c.IdleReturnCPUPercentage > 100 {
+		return fmt.Errorf("invalid idle_return_cpu_percentage value: %f, expected range (0, 100]", c.IdleReturnCPUPercentage)
+	}
+	if c.MaxGCInterval < 0 {
+		return fmt.Errorf("invalid max_gc_interval value: %v, expected non-negative", c.MaxGCInterval)
+	}
+	if c.OOMCooldown < 0 {
+		return fmt.Errorf("invalid oom_cooldown value: %v, expected non-negative", c.OOMCooldown)
+	}
+	if c.OOMRecovery < 0 {
+		return fmt.Errorf("invalid oom_recovery value: %v, expected non-negative", c.OOMRecovery)
+	}
+	if c.GOGCMinChangePercentage < 0 || c.GOGCMinChangePercentage > 100 {
+		return fmt.Errorf("invalid gogc_min_change_percentage value: %f, expected range [0, 100]", c.GOGCMinChangePercentage)
+	}
+	if c.GOGCMaxStepUp < 0 {
+		return fmt.Errorf("invalid gogc_max_step_up value: %d, expected non-negative", c.GOGCMaxStepUp)
+	}
+	if c.GOGCMaxStepDown < 0 {
+		return fmt.Errorf("invalid gogc_max_step_down value: %d, expected non-negative", c.GOGCMaxStepDown)
+	}
+	if c.MinGOGC < 0 {
+		return fmt.Errorf("invalid min_gogc value: %d, expected non-negative", c.MinGOGC)
+	}
+	if c.MinGOGC > 0 && c.GOGC > 0 && c.MinGOGC > c.GOGC {
+		return fmt.Errorf("invalid min_gogc value: %d, expected not above gogc %d", c.MinGOGC, c.GOGC)
+	}
+	if c.EmergencyRAMPercentage < 0 || c.EmergencyRAMPercentage > 100 {
+		return fmt.Errorf("invalid emergency_ram_percentage value: %f, expected range (0, 100]", c.EmergencyRAMPercentage)
+	}
+	if c.RampDuration < 0 {
+		return fmt.Errorf("invalid ramp_duration value: %v, expected non-negative", c.RampDuration)
+	}
+	if c.RampCycles < 0 {
+		return fmt.Errorf("invalid ramp_cycles value: %d, expected non-negative", c.RampCycles)
+	}
+	if c.RollbackWindow < 0 {
+		return fmt.Errorf("invalid rollback_window value: %v, expected non-negative", c.RollbackWindow)
+	}
+	if c.RollbackGCCPUPercentage < 0 {
+		return fmt.Errorf("invalid rollback_gc_cpu_percentage value: %f, expected non-negative", c.RollbackGCCPUPercentage)
+	}
+	if c.RollbackPausePercentage < 0 {
+		return fmt.Errorf("invalid rollback_pause_percentage value: %f, expected non-negative", c.RollbackPausePercentage)
+	}
+	if c.RestartStepPercentage < 0 || c.RestartStepPercentage > 100 {
+		return fmt.Errorf("invalid restart_step_percentage value: %f, expected range (0, 100]", c.RestartStepPercentage)
+	}
+	if c.RestartMaxStepPercentage < 0 || c.RestartMaxStepPercentage > 100 {
+		return fmt.Errorf("invalid restart_max_step_percentage value: %f, expected range (0, 100]", c.RestartMaxStepPercentage)
+	}
+	if c.RestartDecay < 0 {
+		return fmt.Errorf("invalid restart_decay value: %v, expected non-negative", c.RestartDecay)
+	}
+	if c.RequestPercentage < 0 || c.RequestPercentage > 100 {
+		return fmt.Errorf("invalid request_percentage value: %f, expected range (0, 100]", c.RequestPercentage)
+	}
+	if err := c.checkTiers(); err != nil {
+		return err
+	}
+	if c.EmergencyRAMPercentage > 0 && c.MaxRAMPercentage > c.EmergencyRAMPercentage {
+		return fmt.Errorf("invalid max_ram_percentage value: %f, expected not above emergency_ram_percentage %f",
+			c.MaxRAMPercentage, c.EmergencyRAMPercentage)
+	}
 	return nil
 }
 
@@ -109,34 +378,112 @@ func doInit(options ...Option) error {
 		return errNoConfiguratorSpecified
 	}
 
-	h := &adaptiveGCHandler{
-		configurator: o.configurator,
-		logger:       o.logger,
-		ch:           make(chan interface{}, 1),
+	if o.runtime == nil {
+		o.runtime = gcruntime.System
 	}
+
+	h := newAdaptiveGCHandler(o)
 	h.Start()
+	globalHandler.Store(h)
 	return nil
 }
 
 type adaptiveGCHandler struct {
 	configurator Configurator
 	logger       Logger
+	rt           gcruntime.Runtime
+	eventHandler func(Event)
+	trigger      Trigger
+	pollInterval time.Duration
+
+	prevConfig    atomic.Value // the latest config of the configurator
+	appliedConfig atomic.Value // the config applied after the adjustments of the signals
+	ch            chan interface{}
+	done          chan struct{}
+	stopOnce      sync.Once
+
+	statusMu sync.Mutex
+	status   Status
+
+	reserveMu sync.Mutex
+	reserved  uint64 // guarded by reserveMu
+
+	numGC uint32 // the number of GC cycles seen by the GC hook
 
-	prevConfig atomic.Value
-	ch         chan interface{}
+	predictor predictor // accessed by checkAndSetNextGCConfig only
+	ramp      ramp      // accessed by checkAndSetNextGCConfig only
+	guard     guard     // accessed by checkAndSetNextGCConfig only
+
+	memoryLimit uint64 // the latest detected memory limit, accessed by checkAndSetNextGCConfig only
+
+	cycle     gcCycle // the GC cycle of the last evaluation, accessed by checkAndSetNextGCConfig only
+	cycleSeen bool
+
+	requestProvider MemoryRequestProvider
+	requestChecked  time.Time // the time of the last check of the memory request, accessed by watchMemoryRequest only
+	overrideTTL     time.Duration
+
+	stateFile string
+	stateMu   sync.Mutex
+	restart   restart // guarded by stateMu
+}
+
+func newAdaptiveGCHandler(o *opts) *adaptiveGCHandler {
+	a := &adaptiveGCHandler{
+		configurator:    o.configurator,
+		logger:          o.logger,
+		rt:              o.runtime,
+		eventHandler:    o.eventHandler,
+		trigger:         o.trigger,
+		pollInterval:    o.pollInterval,
+		stateFile:       o.stateFile,
+		requestProvider: o.requestProvider,
+		overrideTTL:     o.overrideTTL,
+		ch:              make(chan interface{}, 1),
+		done:            make(chan struct{}),
+	}
+	if a.trigger == 0 {
+		a.trigger = TriggerGCHook
+	}
+	if a.pollInterval <= 0 {
+		a.pollInterval = defaultPollInterval
+	}
+	a.status.Trigger.Trigger = a.trigger
+	return a
 }
 
 func (a *adaptiveGCHandler) Start() {
-	a.checkAndSetNextGCConfig()
-	a.installGCHook()
+	if a.stateFile != "" {
+		a.withRecover(a.loadState)()
+	}
+	if a.requestProvider != nil {
+		a.withRecover(a.checkMemoryRequest)()
+	}
+	a.withRecover(a.checkAndSetNextGCConfig)()
+	if a.trigger&TriggerGCHook != 0 {
+		a.installGCHook()
+	}
+	if a.trigger&TriggerTicker != 0 {
+		go a.withRecover(a.pollGC)()
+	}
 	go a.handleConfigTask()
 	go a.withRecover(a.watchConfigUpdate)()
+	go a.withRecover(a.watchMemoryEvents)()
+	go a.withRecover(a.watchIdle)()
+	go a.withRecover(a.watchGCInterval)()
+	go a.withRecover(a.watchState)()
+	go a.withRecover(a.watchMemoryRequest)()
+}
+
+// Stop stops the background goroutines and the GC hook of the handler, the GC parameters are kept as is.
+func (a *adaptiveGCHandler) Stop() {
+	a.stopOnce.Do(func() {
+		close(a.done)
+	})
 }
 
 func (a *adaptiveGCHandler) installGCHook() {
-	var r = &ref{}
-	runtime.SetFinalizer(r, a.registerNextGCHook)
-	r = nil
+	a.rt.NotifyGC(a.onGC)
 }
 
 func (a *adaptiveGCHandler) checkAndSetNextGCConfig() {
@@ -150,9 +497,36 @@ func (a *adaptiveGCHandler) checkAndSetNextGCConfig() {
 		return
 	}
 
-	oldConfig, _ := a.prevConfig.Load().(Config)
-	setGCParameter(oldConfig, newConfig, a.logger)
+	oldConfig, _ := a.appliedConfig.Load().(Config)
+	tiered, limitChanged := a.applyTiers(a.guardConfig(newConfig))
+	if limitChanged {
+		oldConfig = Config{} // the GC parameters depend on the memory limit
+	}
+	overridden, numOverrides := a.applyOverrides(tiered)
+	appliedConfig := a.adjustConfig(a.applyRamp(overridden))
+	setGCParameter(oldConfig, appliedConfig, a.rt, a.logger, !a.newGCCycle())
 	a.prevConfig.Store(newConfig)
+	a.appliedConfig.Store(appliedConfig)
+	a.updateStatus(func(s *Status) {
+		s.Config = newConfig
+		s.Overrides = numOverrides
+		s.AppliedConfig = appliedConfig
+	})
+}
+
+// adjustConfig applies the signals to the config of the configurator
+func (a *adaptiveGCHandler) adjustConfig(config Config) Config {
+	config = a.applyMemoryRequest(config)
+	config = a.applyStepDown(config)
+	config = a.applyRestartPenalty(config)
+	limited := config
+	config = a.applyNonGoMemory(config)
+	config = a.applyForecast(config)
+	a.updatePressureLevel(limited)
+	if a.checkMemoryPressure(config) {
+		config = tightenConfig(config, a.rt)
+	}
+	return config
 }
 
 func (a *adaptiveGCHandler) watchConfigUpdate() {
@@ -160,7 +534,12 @@ func (a *adaptiveGCHandler) watchConfigUpdate() {
 	if configUpdateCh == nil {
 		return
 	}
-	for range configUpdateCh {
+	for {
+		select {
+		case <-configUpdateCh:
+		case <-a.done:
+			return
+		}
 		newVal, _ := a.configurator.GetConfig()
 		oldVal, _ := a.prevConfig.Load().(Config)
 		if reflect.DeepEqual(newVal, oldVal) {
@@ -175,8 +554,21 @@ func (a *adaptiveGCHandler) watchConfigUpdate() {
 	}
 }
 
+// handleConfigTask re-evaluates the GC parameters on GC cycles and config updates, and periodically,
+// so that the GC parameters are still updated if GC doesn't run
 func (a *adaptiveGCHandler) handleConfigTask() {
-	for range a.ch {
+	t := a.rt.NewTicker(periodicCheckInterval)
+	defer t.Stop()
+	numGC := a.readNumGC()
+	for {
+		select {
+		case <-a.ch:
+		case <-t.C():
+			a.withRecover(a.sampleNonGoMemory)()
+		case <-a.done:
+			return
+		}
+		a.observeLag(&numGC)
 		a.withRecover(a.checkAndSetNextGCConfig)()
 	}
 }
@@ -194,16 +586,19 @@ func (a *adaptiveGCHandler) withRecover(func0 func()) func() {
 	}
 }
 
-func (a *adaptiveGCHandler) registerNextGCHook(f *ref) {
+// onGC is called after every GC cycle, it returns false once the handler is stopped.
+func (a *adaptiveGCHandler) onGC() bool {
+	select {
+	case <-a.done:
+		return false
+	default:
+	}
+	atomic.AddUint32(&a.numGC, 1)
 	select {
 	case a.ch <- struct{}{}:
 	default:
 	}
-	runtime.SetFinalizer(f, a.registerNextGCHook) // Keep the object f alive for the next GC
-}
-
-type ref struct {
-	holder []byte
+	return true
 }
 
 type stdLogger struct{}
@@ -98,6 +98,10 @@ func doBench(tag string, t *testing.T, enableProfiler bool) uint64 {
 	return atomic.LoadUint64(&i)
 }
 
+type ref struct {
+	holder []byte
+}
+
 func installGCHook() {
 	var f = &ref{}
 	runtime.SetFinalizer(f, gcInfoPrinter)
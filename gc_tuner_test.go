@@ -1,8 +1,13 @@
 package gogctuner
 
 import (
+	"fmt"
 	"math"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
 )
 
 // func getGOGC(previousGOGC int , memoryLimitInPercent, memPercent float64) int {
@@ -41,13 +46,117 @@ func TestGetGOGCBasics(t *testing.T) {
 		},
 	}
 	for i, _ := range cases {
-		result := getGOGC(cases[i].MemoryLimitInPercent, cases[i].TotalSize, cases[i].LiveSize, goGCNoLimit)
+		result := getGOGC(cases[i].MemoryLimitInPercent, cases[i].TotalSize, cases[i].LiveSize, goGCNoLimit, 100,
+			minGOGCValue, maxRAMUsagePercentage)
 		if result != cases[i].ExpectedGOGC {
 			t.Errorf("Failed Test Case #%v - Expected: %v Found: %v", i+1, cases[i].ExpectedGOGC, result)
 		}
 	}
 }
 
+type GOGCBoundsTestCase struct {
+	MemoryLimitInPercent float64
+	LiveSize             float64
+	MinGOGC              int
+	EmergencyPercentage  float64
+	ExpectedGOGC         int
+}
+
+func TestGetGOGCBounds(t *testing.T) {
+	cases := []GOGCBoundsTestCase{
+		// the floor
+		{MemoryLimitInPercent: 40, LiveSize: 3800, MinGOGC: 50, EmergencyPercentage: 95, ExpectedGOGC: 50},
+		{MemoryLimitInPercent: 40, LiveSize: 3800, MinGOGC: 1, EmergencyPercentage: 95, ExpectedGOGC: 5},
+		// the live heap exceeds the target, GOGC is limited by the emergency ceiling
+		{MemoryLimitInPercent: 40, LiveSize: 8000, MinGOGC: 10, EmergencyPercentage: 95, ExpectedGOGC: 18},
+		{MemoryLimitInPercent: 40, LiveSize: 8000, MinGOGC: 10, EmergencyPercentage: 98, ExpectedGOGC: 22},
+		{MemoryLimitInPercent: 40, LiveSize: 9000, MinGOGC: 10, EmergencyPercentage: 95, ExpectedGOGC: 10},
+	}
+	for i := range cases {
+		result := getGOGC(cases[i].MemoryLimitInPercent, 10000, cases[i].LiveSize, goGCNoLimit, 100,
+			cases[i].MinGOGC, cases[i].EmergencyPercentage)
+		if result != cases[i].ExpectedGOGC {
+			t.Errorf("Failed Test Case #%v - Expected: %v Found: %v", i+1, cases[i].ExpectedGOGC, result)
+		}
+	}
+}
+
+func TestGOGCBoundsConfig(t *testing.T) {
+	f := func(config Config, expectValid bool) {
+		t.Helper()
+		if err := config.CheckValid(); (err == nil) != expectValid {
+			t.Fatalf("unexpected validity of %+v, got error: %v, want valid %v", config, err, expectValid)
+		}
+	}
+	f(Config{MaxRAMPercentage: 98, MinGOGC: 10, EmergencyRAMPercentage: 98, MinHeapSize: 1 << 20}, true)
+	f(Config{MaxRAMPercentage: 100}, true)
+	f(Config{GOGC: 100, MinGOGC: 100}, true)
+	f(Config{GOGC: 100, MinGOGC: 101}, false)
+	f(Config{MinGOGC: -1}, false)
+	f(Config{EmergencyRAMPercentage: -1}, false)
+	f(Config{EmergencyRAMPercentage: 101}, false)
+	f(Config{MaxRAMPercentage: 96, EmergencyRAMPercentage: 95}, false)
+}
+
+type SmoothGOGCTestCase struct {
+	PreviousGOGC int
+	TargetGOGC   int
+	Tight        bool
+	SameCycle    bool
+	Config       Config
+	ExpectedGOGC int
+}
+
+func TestSmoothGOGC(t *testing.T) {
+	cases := []SmoothGOGCTestCase{
+		// no smoothing
+		{PreviousGOGC: 100, TargetGOGC: 700, ExpectedGOGC: 700},
+		{PreviousGOGC: 0, TargetGOGC: 700, Config: Config{GOGCMaxStepUp: 50}, ExpectedGOGC: 700},
+		{PreviousGOGC: -1, TargetGOGC: 700, Config: Config{GOGCMaxStepUp: 50}, ExpectedGOGC: 700},
+		// minimum relative change
+		{PreviousGOGC: 200, TargetGOGC: 215, Config: Config{GOGCMinChangePercentage: 10}, ExpectedGOGC: 200},
+		{PreviousGOGC: 200, TargetGOGC: 185, Config: Config{GOGCMinChangePercentage: 10}, ExpectedGOGC: 200},
+		{PreviousGOGC: 200, TargetGOGC: 220, Config: Config{GOGCMinChangePercentage: 10}, ExpectedGOGC: 220},
+		{PreviousGOGC: 200, TargetGOGC: 185, Tight: true, Config: Config{GOGCMinChangePercentage: 10}, ExpectedGOGC: 185},
+		// maximum step up
+		{PreviousGOGC: 100, TargetGOGC: 700, Config: Config{GOGCMaxStepUp: 50}, ExpectedGOGC: 150},
+		{PreviousGOGC: 100, TargetGOGC: 130, Config: Config{GOGCMaxStepUp: 50}, ExpectedGOGC: 130},
+		{PreviousGOGC: 700, TargetGOGC: 100, Config: Config{GOGCMaxStepUp: 50}, ExpectedGOGC: 100},
+		// maximum step down
+		{PreviousGOGC: 700, TargetGOGC: 100, Config: Config{GOGCMaxStepDown: 200}, ExpectedGOGC: 500},
+		{PreviousGOGC: 700, TargetGOGC: 600, Config: Config{GOGCMaxStepDown: 200}, ExpectedGOGC: 600},
+		{PreviousGOGC: 100, TargetGOGC: 700, Config: Config{GOGCMaxStepDown: 200}, ExpectedGOGC: 700},
+		// asymmetric: drop fast when the memory is tight, rise slowly
+		{PreviousGOGC: 700, TargetGOGC: 50, Tight: true, Config: Config{GOGCMaxStepUp: 20, GOGCMaxStepDown: 20}, ExpectedGOGC: 50},
+		{PreviousGOGC: 50, TargetGOGC: 700, Tight: true, Config: Config{GOGCMaxStepUp: 20, GOGCMaxStepDown: 20}, ExpectedGOGC: 70},
+		// the steps are taken per GC cycle
+		{PreviousGOGC: 100, TargetGOGC: 700, SameCycle: true, Config: Config{GOGCMaxStepUp: 50}, ExpectedGOGC: 100},
+		{PreviousGOGC: 700, TargetGOGC: 100, SameCycle: true, Config: Config{GOGCMaxStepDown: 200}, ExpectedGOGC: 700},
+		{PreviousGOGC: 700, TargetGOGC: 50, Tight: true, SameCycle: true, Config: Config{GOGCMaxStepDown: 20}, ExpectedGOGC: 50},
+		{PreviousGOGC: 100, TargetGOGC: 700, SameCycle: true, ExpectedGOGC: 700},
+	}
+	for i := range cases {
+		result := smoothGOGC(cases[i].PreviousGOGC, cases[i].TargetGOGC, cases[i].Tight, cases[i].SameCycle, cases[i].Config)
+		if result != cases[i].ExpectedGOGC {
+			t.Errorf("Failed Test Case #%v - Expected: %v Found: %v", i+1, cases[i].ExpectedGOGC, result)
+		}
+	}
+}
+
+func TestGOGCSmoothingConfig(t *testing.T) {
+	f := func(config Config, expectValid bool) {
+		t.Helper()
+		if err := config.CheckValid(); (err == nil) != expectValid {
+			t.Fatalf("unexpected validity of %+v, got error: %v, want valid %v", config, err, expectValid)
+		}
+	}
+	f(Config{GOGCMinChangePercentage: 10, GOGCMaxStepUp: 50, GOGCMaxStepDown: 200}, true)
+	f(Config{GOGCMinChangePercentage: -1}, false)
+	f(Config{GOGCMinChangePercentage: 101}, false)
+	f(Config{GOGCMaxStepUp: -1}, false)
+	f(Config{GOGCMaxStepDown: -1}, false)
+}
+
 func TestGcConfig(t *testing.T) {
 	testGcConfigCheck(t, 0, true)
 	testGcConfigCheck(t, 90, true)
@@ -66,3 +175,88 @@ func testGcConfigCheck(t *testing.T, maxRamPercentage float64, expectValid bool)
 		t.Errorf("%f for MaxRAMPercentage should be %s", maxRamPercentage, expect)
 	}
 }
+
+type testLogger struct {
+	mu     sync.Mutex
+	logs   []string
+	errors []string
+}
+
+func (l *testLogger) Logf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logs = append(l.logs, fmt.Sprintf(format, v...))
+}
+
+func (l *testLogger) Logs() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.logs...)
+}
+
+func (l *testLogger) Errorf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errors = append(l.errors, fmt.Sprintf(format, v...))
+}
+
+func (l *testLogger) Errors() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.errors...)
+}
+
+func newTestHandler(rt *gctunertest.Runtime, configurator Configurator) (*adaptiveGCHandler, *testLogger) {
+	logger := &testLogger{}
+	return newAdaptiveGCHandler(&opts{configurator: configurator, logger: logger, runtime: rt}), logger
+}
+
+func TestHandlerPanicRecovery(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1 << 30)
+	rt.PanicOnSetGCPercent("boom")
+	h, logger := newTestHandler(rt, staticConfigurator{config: Config{GOGC: 200}})
+
+	h.withRecover(h.checkAndSetNextGCConfig)()
+	errs := logger.Errors()
+	if len(errs) != 1 || !strings.Contains(errs[0], "panic: boom") {
+		t.Fatalf("expecting the panic to be logged, got: %q", errs)
+	}
+
+	rt.PanicOnSetGCPercent(nil)
+	h.withRecover(h.checkAndSetNextGCConfig)()
+	if rt.GCPercent() != 200 {
+		t.Fatalf("unexpected GOGC, got: %d, want 200", rt.GCPercent())
+	}
+}
+
+func TestHandlerInvalidConfig(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	h, logger := newTestHandler(rt, staticConfigurator{config: Config{MaxRAMPercentage: 120}})
+	h.checkAndSetNextGCConfig()
+	if len(logger.Errors()) != 1 || rt.GCPercent() != 100 {
+		t.Fatalf("expecting the invalid config to be rejected, errors: %q, GOGC: %d", logger.Errors(), rt.GCPercent())
+	}
+}
+
+func TestHandlerGCHook(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	h, _ := newTestHandler(rt, staticConfigurator{config: Config{GOGC: 200}})
+	h.installGCHook()
+
+	rt.TriggerGC()
+	select {
+	case <-h.ch:
+	default:
+		t.Fatalf("expecting a config task after GC")
+	}
+
+	h.Stop()
+	rt.TriggerGC()
+	rt.TriggerGC()
+	select {
+	case <-h.ch:
+		t.Fatalf("unexpected config task after the handler is stopped")
+	default:
+	}
+}
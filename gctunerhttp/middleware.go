@@ -0,0 +1,160 @@
+// Package gctunerhttp provides net/http admission control driven by the heap pressure level of gctuner.
+//
+// Under critical heap pressure, accepting more requests just pushes the process toward OOM. Middleware sheds
+// a fraction of the new requests with 503 and Retry-After, optionally after queueing them for a while,
+// and ReadinessHandler reports not-ready under sustained pressure:
+//
+//	h, unregister := gctunerhttp.Middleware(handler, gctunerhttp.WithRejectFraction(0.5))
+//	defer unregister()
+//	http.Handle("/", h)
+//	http.Handle("/ready", gctunerhttp.ReadinessHandler(30*time.Second))
+package gctunerhttp
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fangwentong/gogctuner"
+)
+
+const defaultRetryAfter = time.Second
+
+var (
+	// getPressure and now are replaced in tests
+	getPressure = func() gogctuner.PressureInfo {
+		return gogctuner.GetStatus().HeapPressure
+	}
+	now = time.Now
+)
+
+type opts struct {
+	rejectFraction float64
+	retryAfter     time.Duration
+	maxQueue       int
+	queueTimeout   time.Duration
+}
+
+type Option func(*opts)
+
+// WithRejectFraction sets the fraction of the new requests shed under critical pressure, range (0, 1],
+// all the new requests are shed by default.
+func WithRejectFraction(fraction float64) Option {
+	return func(o *opts) {
+		o.rejectFraction = fraction
+	}
+}
+
+// WithRetryAfter sets the Retry-After header of the rejected requests, it's 1 second by default.
+func WithRetryAfter(d time.Duration) Option {
+	return func(o *opts) {
+		o.retryAfter = d
+	}
+}
+
+// WithQueue delays up to maxQueue requests to be shed for at most timeout, the requests are served if the pressure
+// subsides in time, otherwise they're rejected. The requests are rejected immediately by default.
+func WithQueue(maxQueue int, timeout time.Duration) Option {
+	return func(o *opts) {
+		o.maxQueue = maxQueue
+		o.queueTimeout = timeout
+	}
+}
+
+// Middleware rejects a fraction of the new requests with 503 and Retry-After once the heap pressure level is
+// gogctuner.PressureCritical, see gogctuner.OnPressure. The returned unregister removes the pressure callbacks
+// registered for WithQueue, it should be called once the handler is no longer used.
+func Middleware(next http.Handler, options ...Option) (h http.Handler, unregister func()) {
+	o := &opts{rejectFraction: 1, retryAfter: defaultRetryAfter}
+	for _, opt := range options {
+		opt(o)
+	}
+	m := &middleware{next: next, opts: o, relief: newNotifier()}
+	if o.queueTimeout <= 0 {
+		return m, func() {}
+	}
+	// the queued requests are woken up once the level drops below critical
+	unregisterNormal := gogctuner.OnPressure(gogctuner.PressureNormal, m.relief.notify)
+	unregisterElevated := gogctuner.OnPressure(gogctuner.PressureElevated, m.relief.notify)
+	return m, func() {
+		unregisterNormal()
+		unregisterElevated()
+	}
+}
+
+type middleware struct {
+	next   http.Handler
+	opts   *opts
+	relief *notifier
+	queued int64
+}
+
+func (m *middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !m.shed() || m.wait(r) {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int((m.opts.retryAfter+time.Second-1)/time.Second)))
+	http.Error(w, "memory pressure is critical", http.StatusServiceUnavailable)
+}
+
+// shed reports whether the request should be shed
+func (m *middleware) shed() bool {
+	if getPressure().Level != gogctuner.PressureCritical {
+		return false
+	}
+	return m.opts.rejectFraction >= 1 || rand.Float64() < m.opts.rejectFraction
+}
+
+// wait queues the request until the pressure subsides, it reports whether the request can be served
+func (m *middleware) wait(r *http.Request) bool {
+	if m.opts.queueTimeout <= 0 {
+		return false
+	}
+	if atomic.AddInt64(&m.queued, 1) > int64(m.opts.maxQueue) {
+		atomic.AddInt64(&m.queued, -1)
+		return false
+	}
+	defer atomic.AddInt64(&m.queued, -1)
+
+	timer := time.NewTimer(m.opts.queueTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-m.relief.wait():
+			if getPressure().Level != gogctuner.PressureCritical {
+				return true
+			}
+		case <-timer.C:
+			return false
+		case <-r.Context().Done():
+			return false
+		}
+	}
+}
+
+// notifier wakes up the waiters on every notification
+type notifier struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+func newNotifier() *notifier {
+	return &notifier{ch: make(chan struct{})}
+}
+
+func (n *notifier) wait() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.ch
+}
+
+func (n *notifier) notify(gogctuner.PressureInfo) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	close(n.ch)
+	n.ch = make(chan struct{})
+}
@@ -0,0 +1,131 @@
+package gctunerhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fangwentong/gogctuner"
+)
+
+type fakePressure struct {
+	mu   sync.Mutex
+	info gogctuner.PressureInfo
+}
+
+func (f *fakePressure) get() gogctuner.PressureInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.info
+}
+
+func (f *fakePressure) set(level gogctuner.PressureLevel, since time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.info = gogctuner.PressureInfo{Level: level, Since: since}
+}
+
+// usePressure replaces the pressure of gctuner with a fake one until restore is called
+func usePressure() (p *fakePressure, restore func()) {
+	p = &fakePressure{}
+	prev := getPressure
+	getPressure = p.get
+	return p, func() {
+		getPressure = prev
+	}
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+// middlewares creates the middlewares of okHandler, and unregisters them on close
+type middlewares []func()
+
+func (ms *middlewares) new(options ...Option) http.Handler {
+	h, unregister := Middleware(okHandler, options...)
+	*ms = append(*ms, unregister)
+	return h
+}
+
+func (ms middlewares) close() {
+	for _, unregister := range ms {
+		unregister()
+	}
+}
+
+func serve(h http.Handler) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	p, restore := usePressure()
+	defer restore()
+	var ms middlewares
+	defer ms.close()
+	f := func(h http.Handler, level gogctuner.PressureLevel, code int, retryAfter string) {
+		t.Helper()
+		p.set(level, time.Time{})
+		w := serve(h)
+		if w.Code != code || w.Header().Get("Retry-After") != retryAfter {
+			t.Fatalf("unexpected response under %s pressure, got: %d (Retry-After %q), want %d (Retry-After %q)",
+				level, w.Code, w.Header().Get("Retry-After"), code, retryAfter)
+		}
+	}
+
+	h := ms.new()
+	f(h, gogctuner.PressureNormal, http.StatusOK, "")
+	f(h, gogctuner.PressureElevated, http.StatusOK, "")
+	f(h, gogctuner.PressureCritical, http.StatusServiceUnavailable, "1")
+	f(ms.new(WithRetryAfter(1500*time.Millisecond)), gogctuner.PressureCritical, http.StatusServiceUnavailable, "2")
+	f(ms.new(WithRejectFraction(0)), gogctuner.PressureCritical, http.StatusOK, "")
+	f(ms.new(WithQueue(0, time.Second)), gogctuner.PressureCritical, http.StatusServiceUnavailable, "1")
+	f(ms.new(WithQueue(1, time.Millisecond)), gogctuner.PressureCritical, http.StatusServiceUnavailable, "1")
+}
+
+func TestMiddlewareQueue(t *testing.T) {
+	p, restore := usePressure()
+	defer restore()
+	p.set(gogctuner.PressureCritical, time.Time{})
+	h, unregister := Middleware(okHandler, WithQueue(1, time.Minute))
+	defer unregister()
+
+	done := make(chan int)
+	go func() {
+		done <- serve(h).Code
+	}()
+	for atomic.LoadInt64(&h.(*middleware).queued) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// the queue is full
+	if w := serve(h); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected response with the full queue, got: %d", w.Code)
+	}
+
+	p.set(gogctuner.PressureElevated, time.Time{})
+	h.(*middleware).relief.notify(gogctuner.PressureInfo{})
+	if code := <-done; code != http.StatusOK {
+		t.Fatalf("unexpected response of the queued request, got: %d, want 200", code)
+	}
+}
+
+func TestReadinessHandler(t *testing.T) {
+	p, restore := usePressure()
+	defer restore()
+	h := ReadinessHandler(time.Minute)
+	f := func(level gogctuner.PressureLevel, since time.Duration, code int) {
+		t.Helper()
+		p.set(level, time.Now().Add(-since))
+		if w := serve(h); w.Code != code {
+			t.Fatalf("unexpected readiness under %s pressure since %s, got: %d, want %d", level, since, w.Code, code)
+		}
+	}
+	f(gogctuner.PressureNormal, time.Hour, http.StatusOK)
+	f(gogctuner.PressureCritical, time.Second, http.StatusOK)
+	f(gogctuner.PressureCritical, 2*time.Minute, http.StatusServiceUnavailable)
+}
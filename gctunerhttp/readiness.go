@@ -0,0 +1,23 @@
+package gctunerhttp
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fangwentong/gogctuner"
+)
+
+// ReadinessHandler returns a readiness probe handler, which reports not-ready with 503 once the heap pressure
+// level has been gogctuner.PressureCritical for at least sustained, and ready with 200 otherwise.
+func ReadinessHandler(sustained time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := getPressure()
+		if info.Level == gogctuner.PressureCritical && now().Sub(info.Since) >= sustained {
+			http.Error(w, fmt.Sprintf("memory pressure is critical since %s, %.2f%% of the limit",
+				info.Since.Format(time.RFC3339), info.Percentage), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, "ok, memory pressure is %s\n", info.Level)
+	})
+}
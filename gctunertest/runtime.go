@@ -0,0 +1,401 @@
+// Package gctunertest provides a fake Go runtime for deterministic tests of gctuner.
+//
+// The fake runtime records the GC parameters set by the tuner, and lets the test control the memory limit,
+// the live heap, the runtime metrics, the environment and the clock, and trigger GC cycles:
+//
+//	rt := gctunertest.NewRuntime()
+//	rt.SetDetectedMemoryLimit(1 << 30)
+//	_ = gogctuner.EnableGCTuner(gogctuner.WithStaticConfig(config), gogctuner.WithRuntime(rt))
+//	rt.TriggerGC()
+package gctunertest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/fangwentong/gogctuner/internal/cgroup"
+	"github.com/fangwentong/gogctuner/internal/gcruntime"
+)
+
+type (
+	// PSI is the pressure stall information of a resource.
+	PSI = cgroup.PSI
+	// PSIStats are the stall time percentages over 10s, 60s and 300s windows, and the total stall time in µs.
+	PSIStats = cgroup.PSIStats
+	// MemoryEvents are the event counters of the memory cgroup.
+	MemoryEvents = cgroup.MemoryEvents
+	// Histogram is a float64 histogram of runtime/metrics.
+	Histogram = gcruntime.Histogram
+)
+
+var (
+	errNoPSI          = errors.New("pressure stall information is unavailable")
+	errNoMemoryEvents = errors.New("memory events are unavailable")
+)
+
+// Epoch is the initial time of the fake clock.
+var Epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Runtime is a fake gcruntime.Runtime, it's safe for concurrent use.
+type Runtime struct {
+	mu              sync.Mutex
+	gcPercent       int
+	memoryLimit     int64
+	limit           uint64
+	swapLimit       uint64
+	psi             *PSI
+	events          *MemoryEvents
+	rss             uint64
+	liveHeap        uint64
+	metrics         map[string]uint64
+	float64s        map[string]float64
+	histograms      map[string]Histogram
+	env             map[string]string
+	now             time.Time
+	gcHooks         []func() bool
+	tickers         []*ticker
+	numGC           int
+	lastGC          time.Time
+	numFreeOSMemory int
+	panicValue      interface{}
+}
+
+var _ gcruntime.Runtime = (*Runtime)(nil)
+
+// NewRuntime returns a fake runtime with the defaults of the Go runtime: GOGC=100 and no memory limit.
+func NewRuntime() *Runtime {
+	return &Runtime{
+		gcPercent:   100,
+		memoryLimit: math.MaxInt64,
+		metrics:     make(map[string]uint64),
+		float64s:    make(map[string]float64),
+		histograms:  make(map[string]Histogram),
+		env:         make(map[string]string),
+		now:         Epoch,
+	}
+}
+
+// SetGCPercent implements gcruntime.Runtime.
+func (r *Runtime) SetGCPercent(percent int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.panicValue != nil {
+		panic(r.panicValue)
+	}
+	prev := r.gcPercent
+	r.gcPercent = percent
+	return prev
+}
+
+// PanicOnSetGCPercent makes SetGCPercent panic with v, nil restores the normal behavior.
+func (r *Runtime) PanicOnSetGCPercent(v interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.panicValue = v
+}
+
+// SetMemoryLimit implements gcruntime.Runtime.
+func (r *Runtime) SetMemoryLimit(limit int64) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prev := r.memoryLimit
+	if limit >= 0 {
+		r.memoryLimit = limit
+	}
+	return prev
+}
+
+// GCPercent returns the current GOGC setting.
+func (r *Runtime) GCPercent() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.gcPercent
+}
+
+// GCMemoryLimit returns the current soft memory limit setting.
+func (r *Runtime) GCMemoryLimit() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.memoryLimit
+}
+
+// ReadMetric implements gcruntime.Runtime, it fails for the metrics not set by SetMetric.
+func (r *Runtime) ReadMetric(name string) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.metrics[name]
+	if !ok {
+		return 0, fmt.Errorf("metric %q no longer supported", name)
+	}
+	return v, nil
+}
+
+// SetMetric sets the value of a runtime metric.
+func (r *Runtime) SetMetric(name string, value uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics[name] = value
+}
+
+// ReadFloat64Metric implements gcruntime.Runtime, it fails for the metrics not set by SetFloat64Metric.
+func (r *Runtime) ReadFloat64Metric(name string) (float64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.float64s[name]
+	if !ok {
+		return 0, fmt.Errorf("metric %q no longer supported", name)
+	}
+	return v, nil
+}
+
+// SetFloat64Metric sets the value of a float64 runtime metric.
+func (r *Runtime) SetFloat64Metric(name string, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.float64s[name] = value
+}
+
+// ReadHistogramMetric implements gcruntime.Runtime, it fails for the metrics not set by SetHistogramMetric.
+func (r *Runtime) ReadHistogramMetric(name string) (Histogram, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, ok := r.histograms[name]
+	if !ok {
+		return Histogram{}, fmt.Errorf("metric %q no longer supported", name)
+	}
+	return Histogram{Counts: append([]uint64(nil), h.Counts...), Buckets: h.Buckets}, nil
+}
+
+// SetHistogramMetric sets the value of a float64 histogram runtime metric.
+func (r *Runtime) SetHistogramMetric(name string, h Histogram) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.histograms[name] = Histogram{Counts: append([]uint64(nil), h.Counts...), Buckets: h.Buckets}
+}
+
+// LiveDatasetSize implements gcruntime.Runtime.
+func (r *Runtime) LiveDatasetSize() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.liveHeap
+}
+
+// SetLiveHeap sets the live dataset size.
+func (r *Runtime) SetLiveHeap(bytes uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.liveHeap = bytes
+}
+
+// NotifyGC implements gcruntime.Runtime.
+func (r *Runtime) NotifyGC(f func() bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gcHooks = append(r.gcHooks, f)
+}
+
+// TriggerGC simulates a GC cycle, the GC hooks are called synchronously.
+func (r *Runtime) TriggerGC() {
+	r.mu.Lock()
+	r.numGC++
+	r.lastGC = r.now
+	hooks := r.gcHooks
+	r.gcHooks = nil
+	r.mu.Unlock()
+
+	var kept []func() bool
+	for _, f := range hooks {
+		if f() {
+			kept = append(kept, f)
+		}
+	}
+
+	r.mu.Lock()
+	r.gcHooks = append(kept, r.gcHooks...)
+	r.mu.Unlock()
+}
+
+// LastGC implements gcruntime.Runtime, it's the time of the last TriggerGC.
+func (r *Runtime) LastGC() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastGC
+}
+
+// GC implements gcruntime.Runtime, it's the same as TriggerGC.
+func (r *Runtime) GC() {
+	r.TriggerGC()
+}
+
+// FreeOSMemory implements gcruntime.Runtime, it's the same as TriggerGC, and counted by NumFreeOSMemory.
+func (r *Runtime) FreeOSMemory() {
+	r.mu.Lock()
+	r.numFreeOSMemory++
+	r.mu.Unlock()
+	r.TriggerGC()
+}
+
+// NumFreeOSMemory returns the number of FreeOSMemory calls.
+func (r *Runtime) NumFreeOSMemory() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.numFreeOSMemory
+}
+
+// NumGC returns the number of simulated GC cycles.
+func (r *Runtime) NumGC() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.numGC
+}
+
+// ReadMemoryLimit implements gcruntime.Runtime.
+func (r *Runtime) ReadMemoryLimit() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.limit
+}
+
+// SetDetectedMemoryLimit sets the memory limit returned by ReadMemoryLimit, e.g. the cgroup memory limit.
+func (r *Runtime) SetDetectedMemoryLimit(bytes uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limit = bytes
+}
+
+// ReadSwapLimit implements gcruntime.Runtime.
+func (r *Runtime) ReadSwapLimit() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.swapLimit
+}
+
+// SetDetectedSwapLimit sets the swap limit returned by ReadSwapLimit.
+func (r *Runtime) SetDetectedSwapLimit(bytes uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.swapLimit = bytes
+}
+
+// ReadMemoryPressure implements gcruntime.Runtime, it fails until SetMemoryPressure is called.
+func (r *Runtime) ReadMemoryPressure() (PSI, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.psi == nil {
+		return PSI{}, errNoPSI
+	}
+	return *r.psi, nil
+}
+
+// SetMemoryPressure sets the memory pressure returned by ReadMemoryPressure.
+func (r *Runtime) SetMemoryPressure(psi PSI) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.psi = &psi
+}
+
+// ReadMemoryEvents implements gcruntime.Runtime, it fails until SetMemoryEvents is called.
+func (r *Runtime) ReadMemoryEvents() (MemoryEvents, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.events == nil {
+		return MemoryEvents{}, errNoMemoryEvents
+	}
+	return *r.events, nil
+}
+
+// SetMemoryEvents sets the memory event counters returned by ReadMemoryEvents.
+func (r *Runtime) SetMemoryEvents(events MemoryEvents) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = &events
+}
+
+// ReadRSS implements gcruntime.Runtime.
+func (r *Runtime) ReadRSS() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rss
+}
+
+// SetRSS sets the resident set size returned by ReadRSS.
+func (r *Runtime) SetRSS(bytes uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rss = bytes
+}
+
+// Getenv implements gcruntime.Runtime.
+func (r *Runtime) Getenv(key string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.env[key]
+}
+
+// Setenv sets an environment variable of the fake runtime, the real environment is not changed.
+func (r *Runtime) Setenv(key, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.env[key] = value
+}
+
+// Now implements gcruntime.Runtime.
+func (r *Runtime) Now() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.now
+}
+
+// NewTicker implements gcruntime.Runtime, the ticker ticks when the clock is advanced by Advance.
+func (r *Runtime) NewTicker(d time.Duration) gcruntime.Ticker {
+	if d <= 0 {
+		panic(errors.New("non-positive interval for NewTicker"))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := &ticker{rt: r, period: d, next: r.now.Add(d), c: make(chan time.Time, 1)}
+	r.tickers = append(r.tickers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, and delivers the ticks of the tickers due within d.
+// Like time.Ticker, ticks are dropped if the receiver is not ready.
+func (r *Runtime) Advance(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.now = r.now.Add(d)
+	for _, t := range r.tickers {
+		for !t.next.After(r.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+type ticker struct {
+	rt     *Runtime
+	period time.Duration
+	next   time.Time
+	c      chan time.Time
+}
+
+func (t *ticker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *ticker) Stop() {
+	t.rt.mu.Lock()
+	defer t.rt.mu.Unlock()
+	for i, other := range t.rt.tickers {
+		if other == t {
+			t.rt.tickers = append(t.rt.tickers[:i], t.rt.tickers[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package gctunertest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTicker(t *testing.T) {
+	rt := NewRuntime()
+	ticker := rt.NewTicker(time.Second)
+	rt.Advance(500 * time.Millisecond)
+	select {
+	case <-ticker.C():
+		t.Fatalf("unexpected tick before the period")
+	default:
+	}
+	rt.Advance(3 * time.Second)
+	if tick := <-ticker.C(); !tick.Equal(Epoch.Add(time.Second)) {
+		t.Fatalf("unexpected tick, got: %v, want %v", tick, Epoch.Add(time.Second))
+	}
+	ticker.Stop()
+	rt.Advance(time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatalf("unexpected tick after stop")
+	default:
+	}
+}
+
+func TestTriggerGC(t *testing.T) {
+	rt := NewRuntime()
+	calls := 0
+	rt.NotifyGC(func() bool {
+		calls++
+		return calls < 2
+	})
+	for i := 0; i < 3; i++ {
+		rt.TriggerGC()
+	}
+	if calls != 2 || rt.NumGC() != 3 {
+		t.Fatalf("unexpected hook calls, got: %d, want 2", calls)
+	}
+}
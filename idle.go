@@ -0,0 +1,124 @@
+package gogctuner
+
+import (
+	"time"
+)
+
+const (
+	heapAllocsMetric = "/gc/heap/allocs:bytes"
+
+	idleCheckInterval              = 10 * time.Second
+	defaultIdleAllocRate           = 1 << 20
+	defaultIdleReturnCPUPercentage = 1
+)
+
+// IdleReturn is the memory returned to the OS when the process is idle, see Config.IdleReturnInterval
+type IdleReturn struct {
+	// AllocRate is the latest allocation rate in bytes per second
+	AllocRate uint64
+	// Count is the number of the returns
+	Count uint64
+	// Last is the time of the last return
+	Last time.Time
+	// LastReleased is the memory released to the OS by the last return, see /memory/classes/heap/released:bytes
+	LastReleased uint64
+	// Released is the total memory released to the OS by the returns
+	Released uint64
+	// Duration is the total time spent in the returns
+	Duration time.Duration
+	// Error is the error of the latest check
+	Error string
+}
+
+// idleState is the state of the idle checks
+type idleState struct {
+	allocs     uint64
+	time       time.Time
+	nextReturn time.Time
+}
+
+// watchIdle checks whether the process is idle periodically, and returns the free memory to the OS if it is
+func (a *adaptiveGCHandler) watchIdle() {
+	t := a.rt.NewTicker(idleCheckInterval)
+	defer t.Stop()
+	var s idleState
+	for {
+		select {
+		case <-t.C():
+		case <-a.done:
+			return
+		}
+		a.checkIdle(&s)
+	}
+}
+
+func (a *adaptiveGCHandler) checkIdle(s *idleState) {
+	config, _ := a.appliedConfig.Load().(Config)
+	if config.IdleReturnInterval <= 0 {
+		*s = idleState{}
+		return
+	}
+	allocs, err := a.rt.ReadMetric(heapAllocsMetric)
+	if err != nil {
+		a.updateStatus(func(st *Status) {
+			st.IdleReturn.Error = err.Error()
+		})
+		return
+	}
+	now := a.rt.Now()
+	prevAllocs, prevTime := s.allocs, s.time
+	s.allocs, s.time = allocs, now
+	if prevTime.IsZero() || !now.After(prevTime) || allocs < prevAllocs {
+		return
+	}
+
+	rate := uint64(float64(allocs-prevAllocs) / now.Sub(prevTime).Seconds())
+	a.updateStatus(func(st *Status) {
+		st.IdleReturn.AllocRate, st.IdleReturn.Error = rate, ""
+	})
+	idleRate := config.IdleAllocRate
+	if idleRate == 0 {
+		idleRate = defaultIdleAllocRate
+	}
+	if rate >= idleRate || now.Before(s.nextReturn) {
+		return
+	}
+
+	elapsed, released := a.returnMemory()
+	budget := config.IdleReturnCPUPercentage
+	if budget == 0 {
+		budget = defaultIdleReturnCPUPercentage
+	}
+	wait := time.Duration(float64(elapsed) * 100 / budget)
+	if wait < config.IdleReturnInterval {
+		wait = config.IdleReturnInterval
+	}
+	s.nextReturn = now.Add(wait)
+	a.logger.Logf("gctuner: process is idle, allocation rate %s/s, released %s to the OS in %v",
+		printMemorySize(rate), printMemorySize(released), elapsed)
+	a.updateStatus(func(st *Status) {
+		r := &st.IdleReturn
+		r.Count++
+		r.Last = now
+		r.LastReleased = released
+		r.Released += released
+		r.Duration += elapsed
+	})
+	// the allocations of the return are not counted
+	if allocs, err = a.rt.ReadMetric(heapAllocsMetric); err == nil {
+		s.allocs, s.time = allocs, a.rt.Now()
+	}
+}
+
+// returnMemory returns the free memory to the OS, and reports the time spent and the memory released
+func (a *adaptiveGCHandler) returnMemory() (time.Duration, uint64) {
+	before, _ := a.rt.ReadMetric(goMemoryReleasedMetric)
+	start := a.rt.Now()
+	a.rt.FreeOSMemory()
+	elapsed := a.rt.Now().Sub(start)
+	after, _ := a.rt.ReadMetric(goMemoryReleasedMetric)
+	if after < before {
+		return elapsed, 0
+	}
+	return elapsed, after - before
+}
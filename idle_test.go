@@ -0,0 +1,71 @@
+package gogctuner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestIdleReturn(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	config := Config{GOGC: 100, IdleReturnInterval: time.Minute, IdleAllocRate: 1 << 20, IdleReturnCPUPercentage: 10}
+	h, _ := newTestHandler(rt, staticConfigurator{config: config})
+	h.checkAndSetNextGCConfig()
+
+	var allocs, released uint64
+	rt.SetMetric(goMemoryReleasedMetric, 0)
+	var s idleState
+	f := func(allocRate uint64, interval time.Duration, returns int) {
+		t.Helper()
+		rt.Advance(interval)
+		allocs += allocRate * uint64(interval/time.Second)
+		rt.SetMetric(heapAllocsMetric, allocs)
+		h.checkIdle(&s)
+		if n := rt.NumFreeOSMemory(); n != returns {
+			t.Fatalf("unexpected number of returns, got: %d, want %d", n, returns)
+		}
+	}
+
+	// the return takes cost and releases 100MB
+	cost := 2 * time.Second
+	rt.NotifyGC(func() bool {
+		rt.Advance(cost)
+		released += 100 << 20
+		rt.SetMetric(goMemoryReleasedMetric, released)
+		return true
+	})
+
+	f(0, 10*time.Second, 0) // the first sample
+	f(10<<20, 10*time.Second, 0)
+	f(100<<10, 10*time.Second, 1)
+	f(100<<10, 10*time.Second, 1) // IdleReturnInterval
+	f(100<<10, 50*time.Second, 2)
+	st := h.Status().IdleReturn
+	if st.Count != 2 || st.Released != 200<<20 || st.LastReleased != 100<<20 || st.Duration != 4*time.Second {
+		t.Fatalf("unexpected idle return status, got: %+v", st)
+	}
+	if st.AllocRate != 100<<10 {
+		t.Fatalf("unexpected allocation rate, got: %d, want %d", st.AllocRate, 100<<10)
+	}
+
+	// the CPU budget: 10s at 10% takes 100s
+	cost = 10 * time.Second
+	f(0, time.Minute, 3)
+	f(0, time.Minute, 3)
+	f(0, 40*time.Second, 4)
+}
+
+func TestIdleReturnDisabled(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	h, _ := newTestHandler(rt, staticConfigurator{config: Config{GOGC: 100}})
+	h.checkAndSetNextGCConfig()
+	var s idleState
+	for i := 0; i < 3; i++ {
+		rt.Advance(time.Minute)
+		h.checkIdle(&s)
+	}
+	if rt.NumFreeOSMemory() != 0 {
+		t.Fatalf("unexpected idle returns")
+	}
+}
@@ -0,0 +1,79 @@
+package cgroup
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MemoryEvents are the event counters of the memory cgroup.
+// See https://docs.kernel.org/admin-guide/cgroup-v2.html#memory-interface-files
+type MemoryEvents struct {
+	// High is the number of times the processes are throttled and routed to reclaim because of memory.high.
+	High uint64
+	// Max is the number of times the memory usage was about to go over the limit, i.e. memory.max in cgroup v2
+	// and memory.failcnt in cgroup v1.
+	Max uint64
+	// OOM is the number of times the memory usage hit the limit and the allocation failed.
+	OOM uint64
+	// OOMKill is the number of processes killed by the OOM killer, including the other processes in the cgroup.
+	OOMKill uint64
+}
+
+// GetMemoryEvents returns the event counters of the memory cgroup, from memory.events in cgroup v2,
+// or memory.failcnt and memory.oom_control in cgroup v1.
+func (f FS) GetMemoryEvents() (MemoryEvents, error) {
+	if data, err := f.getFileContents("memory.oom_control", "/sys/fs/cgroup/memory", "memory"); err == nil {
+		var events MemoryEvents
+		if err = parseMemoryEvents(data, map[string]*uint64{"oom_kill": &events.OOMKill}); err != nil {
+			return MemoryEvents{}, err
+		}
+		failcnt, err := f.getMemStat("memory.failcnt")
+		if err != nil {
+			return MemoryEvents{}, err
+		}
+		events.Max = uint64(failcnt)
+		return events, nil
+	}
+	data, err := f.getFileContents("memory.events", "/sys/fs/cgroup", "")
+	if err != nil {
+		return MemoryEvents{}, err
+	}
+	var events MemoryEvents
+	err = parseMemoryEvents(data, map[string]*uint64{
+		"high":     &events.High,
+		"max":      &events.Max,
+		"oom":      &events.OOM,
+		"oom_kill": &events.OOMKill,
+	})
+	if err != nil {
+		return MemoryEvents{}, err
+	}
+	return events, nil
+}
+
+// parseMemoryEvents parses the flat keyed content of memory.events or memory.oom_control, e.g.
+//
+//	low 0
+//	high 12
+//	max 3
+//	oom 1
+//	oom_kill 1
+func parseMemoryEvents(data string, counters map[string]*uint64) error {
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		counter, ok := counters[fields[0]]
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("cannot parse %q: %v", line, err)
+		}
+		*counter = n
+	}
+	return nil
+}
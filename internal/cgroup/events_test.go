@@ -0,0 +1,32 @@
+package cgroup
+
+import (
+	"testing"
+)
+
+func TestGetMemoryEvents(t *testing.T) {
+	f := func(fs FS, want MemoryEvents) {
+		t.Helper()
+		events, err := fs.GetMemoryEvents()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if events != want {
+			t.Fatalf("unexpected memory events, got: %+v, want %+v", events, want)
+		}
+	}
+	f(FS{Root: "testdata/inspect/v1"}, MemoryEvents{Max: 7, OOMKill: 2})
+	f(FS{Root: "testdata/inspect/v2"}, MemoryEvents{High: 12, Max: 3, OOM: 1, OOMKill: 1})
+
+	// the worker cgroup has no event files
+	if _, err := (FS{Root: "testdata/inspect/v1", PID: "1234"}).GetMemoryEvents(); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+func TestParseMemoryEventsFailure(t *testing.T) {
+	var n uint64
+	if err := parseMemoryEvents("oom_kill x", map[string]*uint64{"oom_kill": &n}); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
@@ -0,0 +1,80 @@
+package cgroup
+
+import (
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// FS locates the proc and cgroup filesystems of a process.
+type FS struct {
+	// Root is the directory where the root filesystem is mounted, e.g. /host in a privileged sidecar,
+	// "" for the root of the current mount namespace.
+	Root string
+	// PID is the process whose cgroup is read, "self" if empty.
+	PID string
+}
+
+// Default is the FS of the current process.
+var Default = FS{}
+
+// Path returns the path of an absolute path p under the root.
+func (f FS) Path(p string) string {
+	if f.Root == "" {
+		return p
+	}
+	return path.Join(f.Root, p)
+}
+
+// ProcDir returns the proc directory of the process, e.g. /proc/self.
+func (f FS) ProcDir() string {
+	return f.Path(path.Join("/proc", f.pid()))
+}
+
+func (f FS) pid() string {
+	if f.PID == "" {
+		return "self"
+	}
+	return f.PID
+}
+
+// Inspect reports the memory cgroup of the process.
+func (f FS) Inspect() Report {
+	return inspect(f.ProcDir(), f.Root)
+}
+
+// getStat reads a stat of the memory controller. The cgroup of the current process is read like
+// getStatGeneric does, which also works in a container without the mount information, and the cgroup of
+// another process is read from the directory resolved by Inspect.
+func (f FS) getStat(statName, sysfsPrefix, cgroupGrepLine string) (int64, error) {
+	if f.pid() == "self" {
+		return getStatGeneric(statName, f.Path(sysfsPrefix), f.Path("/proc/self/cgroup"), cgroupGrepLine)
+	}
+	data, err := f.readMemoryFile(statName)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(data), 10, 64)
+}
+
+// getFileContents reads a file of the memory controller, see getStat.
+func (f FS) getFileContents(statName, sysfsPrefix, cgroupGrepLine string) (string, error) {
+	if f.pid() == "self" {
+		return getFileContents(statName, f.Path(sysfsPrefix), f.Path("/proc/self/cgroup"), cgroupGrepLine)
+	}
+	return f.readMemoryFile(statName)
+}
+
+// readMemoryFile reads a file in the memory cgroup directory resolved by Inspect
+func (f FS) readMemoryFile(statName string) (string, error) {
+	r := f.Inspect()
+	if r.Version == 0 {
+		return "", errNoMemoryCgroup
+	}
+	data, err := ioutil.ReadFile(path.Join(r.Dir, statName))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
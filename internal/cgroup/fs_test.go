@@ -0,0 +1,56 @@
+package cgroup
+
+import (
+	"testing"
+)
+
+func TestFSMemoryStats(t *testing.T) {
+	f := func(fs FS, wantLimit, wantHierarchicalLimit, wantUsage int64) {
+		t.Helper()
+		if got := fs.GetMemoryLimit(); got != wantLimit {
+			t.Fatalf("unexpected memory limit, got: %d, want %d", got, wantLimit)
+		}
+		if got := fs.GetHierarchicalMemoryLimit(); got != wantHierarchicalLimit {
+			t.Fatalf("unexpected hierarchical memory limit, got: %d, want %d", got, wantHierarchicalLimit)
+		}
+		if got := fs.GetMemoryUsage(); got != wantUsage {
+			t.Fatalf("unexpected memory usage, got: %d, want %d", got, wantUsage)
+		}
+	}
+	f(FS{Root: "testdata/inspect/v1"}, 1<<30, 512<<20, 100<<20)
+	f(FS{Root: "testdata/inspect/v1", PID: "1234"}, 256<<20, 256<<20, 50<<20)
+	f(FS{Root: "testdata/inspect/v2"}, 2<<30, 0, 200<<20)
+	f(FS{Root: "testdata/none_existing_folder"}, 0, 0, 0)
+	f(FS{Root: "testdata/none_existing_folder", PID: "1234"}, 0, 0, 0)
+}
+
+func TestFSPath(t *testing.T) {
+	f := func(fs FS, p, want string) {
+		t.Helper()
+		if got := fs.Path(p); got != want {
+			t.Fatalf("unexpected path, got: %q, want %q", got, want)
+		}
+	}
+	f(FS{}, "/proc/self/cgroup", "/proc/self/cgroup")
+	f(FS{Root: "/host"}, "/sys/fs/cgroup", "/host/sys/fs/cgroup")
+	f(FS{Root: "testdata"}, "/sys/fs/cgroup", "testdata/sys/fs/cgroup")
+	if got := (FS{Root: "/host", PID: "42"}).ProcDir(); got != "/host/proc/42" {
+		t.Fatalf("unexpected proc dir, got: %q", got)
+	}
+}
+
+func TestFSSwapStats(t *testing.T) {
+	f := func(fs FS, wantLimit, wantUsage int64) {
+		t.Helper()
+		if got := fs.GetSwapLimit(); got != wantLimit {
+			t.Fatalf("unexpected swap limit, got: %d, want %d", got, wantLimit)
+		}
+		if got := fs.GetSwapUsage(); got != wantUsage {
+			t.Fatalf("unexpected swap usage, got: %d, want %d", got, wantUsage)
+		}
+	}
+	f(FS{Root: "testdata/inspect/v1"}, 1<<30, 10<<20)
+	f(FS{Root: "testdata/inspect/v1", PID: "1234"}, -1, -1)
+	f(FS{Root: "testdata/inspect/v2"}, -1, 10<<20)
+	f(FS{Root: "testdata/none_existing_folder"}, -1, -1)
+}
@@ -0,0 +1,230 @@
+package cgroup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Mount is a cgroup filesystem mount, parsed from /proc/<pid>/mountinfo.
+	Mount struct {
+		Version     int      `json:"version"`
+		Root        string   `json:"root"`
+		MountPoint  string   `json:"mount_point"`
+		Controllers []string `json:"controllers,omitempty"`
+	}
+
+	// Path is a cgroup membership of a process, parsed from /proc/<pid>/cgroup.
+	Path struct {
+		HierarchyID string   `json:"hierarchy_id"`
+		Controllers []string `json:"controllers,omitempty"`
+		Path        string   `json:"path"`
+	}
+
+	// Stat is a cgroup memory interface file value.
+	Stat struct {
+		Name      string `json:"name"`
+		File      string `json:"file,omitempty"`
+		Value     int64  `json:"value"`
+		Unlimited bool   `json:"unlimited,omitempty"`
+		Error     string `json:"error,omitempty"`
+	}
+
+	// Report describes the memory cgroup of a process.
+	Report struct {
+		// Version is the cgroup version of the memory controller, 0 if no memory cgroup is found.
+		Version int     `json:"version"`
+		Mounts  []Mount `json:"mounts"`
+		Paths   []Path  `json:"paths"`
+		// Dir is the resolved memory cgroup directory of the process.
+		Dir   string `json:"dir,omitempty"`
+		Stats []Stat `json:"stats"`
+	}
+)
+
+// Inspect reports the memory cgroup of the current process.
+// The cgroup directory is resolved from the mount of the memory controller and the cgroup path of the process.
+func Inspect() Report {
+	return Default.Inspect()
+}
+
+// inspect reports the memory cgroup of the process whose proc directory is procDir, e.g. /proc/self,
+// all mount points are prefixed by rootPrefix
+func inspect(procDir, rootPrefix string) Report {
+	var r Report
+	if data, err := ioutil.ReadFile(path.Join(procDir, "mountinfo")); err == nil {
+		r.Mounts = parseMountInfo(string(data))
+	}
+	if data, err := ioutil.ReadFile(path.Join(procDir, "cgroup")); err == nil {
+		r.Paths = parseCgroupPaths(string(data))
+	}
+
+	r.Version, r.Dir = resolveMemoryDir(r.Mounts, r.Paths)
+	if r.Dir != "" {
+		r.Dir = path.Join(rootPrefix, r.Dir)
+	}
+	switch r.Version {
+	case 1:
+		r.Stats = []Stat{
+			readStat("memory.limit_in_bytes", r.Dir),
+			readHierarchicalStat(r.Dir),
+			readStat("memory.usage_in_bytes", r.Dir),
+			readStat("memory.memsw.limit_in_bytes", r.Dir),
+			readStat("memory.memsw.usage_in_bytes", r.Dir),
+		}
+	case 2:
+		r.Stats = []Stat{
+			readStat("memory.max", r.Dir),
+			readStat("memory.high", r.Dir),
+			readStat("memory.current", r.Dir),
+			readStat("memory.swap.max", r.Dir),
+			readStat("memory.swap.current", r.Dir),
+		}
+	}
+	return r
+}
+
+// Stat returns the stat by its name, and whether it's found.
+func (r Report) Stat(name string) (Stat, bool) {
+	for _, s := range r.Stats {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Stat{}, false
+}
+
+func readStat(name, dir string) Stat {
+	s := Stat{Name: name, File: path.Join(dir, name)}
+	data, err := ioutil.ReadFile(s.File)
+	if err != nil {
+		s.Error = err.Error()
+		return s
+	}
+	v := strings.TrimSpace(string(data))
+	if v == "max" {
+		s.Unlimited = true
+		return s
+	}
+	if s.Value, err = strconv.ParseInt(v, 10, 64); err != nil {
+		s.Error = fmt.Sprintf("cannot parse %q: %v", s.File, err)
+	}
+	return s
+}
+
+func readHierarchicalStat(dir string) Stat {
+	s := Stat{Name: "hierarchical_memory_limit", File: path.Join(dir, "memory.stat")}
+	data, err := ioutil.ReadFile(s.File)
+	if err == nil {
+		s.Value, err = parseHierarchicalMemoryLimit(string(data))
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	return s
+}
+
+// resolveMemoryDir returns the cgroup version and the directory of the memory controller,
+// a cgroup v1 memory controller is preferred in the hybrid mode.
+func resolveMemoryDir(mounts []Mount, paths []Path) (int, string) {
+	for _, version := range []int{1, 2} {
+		for _, m := range mounts {
+			if m.Version != version || (version == 1 && !contains(m.Controllers, "memory")) {
+				continue
+			}
+			for _, p := range paths {
+				if (version == 1 && contains(p.Controllers, "memory")) || (version == 2 && p.HierarchyID == "0") {
+					return version, path.Join(m.MountPoint, relativePath(p.Path, m.Root))
+				}
+			}
+		}
+	}
+	return 0, ""
+}
+
+// relativePath returns the cgroup path relative to the root of the mount,
+// the mount root is the cgroup path of the container if the cgroup namespace is not used.
+func relativePath(cgroupPath, mountRoot string) string {
+	if mountRoot == "/" {
+		return cgroupPath
+	}
+	if cgroupPath == mountRoot {
+		return "/"
+	}
+	if strings.HasPrefix(cgroupPath, mountRoot+"/") {
+		return strings.TrimPrefix(cgroupPath, mountRoot)
+	}
+	// the cgroup path is not visible from the mount, e.g. the mount of another cgroup namespace
+	return "/"
+}
+
+// cgroupMountOptions are the cgroup v1 super block options which are not controllers
+var cgroupMountOptions = []string{"rw", "ro", "none", "noprefix", "xattr", "clone_children", "cpuset_v2_mode"}
+
+// parseMountInfo parses the cgroup mounts from the content of /proc/<pid>/mountinfo, e.g.
+//
+//	36 32 0:32 / /sys/fs/cgroup/memory rw,relatime - cgroup cgroup rw,memory
+//	42 32 0:38 / /sys/fs/cgroup/unified rw,relatime - cgroup2 cgroup2 rw
+//
+// see https://www.kernel.org/doc/Documentation/filesystems/proc.txt
+func parseMountInfo(data string) []Mount {
+	var mounts []Mount
+	for _, line := range strings.Split(data, "\n") {
+		sep := strings.Index(line, " - ")
+		if sep < 0 {
+			continue
+		}
+		fields, super := strings.Fields(line[:sep]), strings.Fields(line[sep+3:])
+		if len(fields) < 5 || len(super) < 3 {
+			continue
+		}
+		m := Mount{Root: fields[3], MountPoint: fields[4]}
+		switch super[0] {
+		case "cgroup":
+			m.Version = 1
+			for _, opt := range strings.Split(super[2], ",") {
+				if !contains(cgroupMountOptions, opt) && !strings.Contains(opt, "=") {
+					m.Controllers = append(m.Controllers, opt)
+				}
+			}
+		case "cgroup2":
+			m.Version = 2
+		default:
+			continue
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts
+}
+
+// parseCgroupPaths parses the content of /proc/<pid>/cgroup, e.g.
+//
+//	4:memory:/docker/74c9abf42b88
+//	0::/system.slice/containerd.service
+func parseCgroupPaths(data string) []Path {
+	var paths []Path
+	for _, line := range strings.Split(data, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		p := Path{HierarchyID: parts[0], Path: parts[2]}
+		if parts[1] != "" {
+			p.Controllers = strings.Split(parts[1], ",")
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package cgroup
+
+import (
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	f := func(fs FS, wantVersion int, wantDir string, want map[string]Stat) {
+		t.Helper()
+		r := fs.Inspect()
+		if r.Version != wantVersion {
+			t.Fatalf("unexpected version, got: %d, want %d", r.Version, wantVersion)
+		}
+		if r.Dir != wantDir {
+			t.Fatalf("unexpected dir, got: %q, want %q", r.Dir, wantDir)
+		}
+		for name, want := range want {
+			got, ok := r.Stat(name)
+			if !ok {
+				t.Fatalf("missing stat %q", name)
+			}
+			if got.Value != want.Value || got.Unlimited != want.Unlimited || got.Error != "" {
+				t.Fatalf("unexpected stat %q, got: %+v, want %+v", name, got, want)
+			}
+		}
+	}
+	f(FS{Root: "testdata/inspect/v1"}, 1, "testdata/inspect/v1/sys/fs/cgroup/memory", map[string]Stat{
+		"memory.limit_in_bytes":     {Value: 1 << 30},
+		"hierarchical_memory_limit": {Value: 512 << 20},
+		"memory.usage_in_bytes":     {Value: 100 << 20},
+	})
+	f(FS{Root: "testdata/inspect/v1", PID: "1234"}, 1, "testdata/inspect/v1/sys/fs/cgroup/memory/worker", map[string]Stat{
+		"memory.limit_in_bytes":     {Value: 256 << 20},
+		"hierarchical_memory_limit": {Value: 256 << 20},
+		"memory.usage_in_bytes":     {Value: 50 << 20},
+	})
+	f(FS{Root: "testdata/inspect/v2"}, 2, "testdata/inspect/v2/sys/fs/cgroup/app.slice/app.service", map[string]Stat{
+		"memory.max":     {Value: 2 << 30},
+		"memory.high":    {Unlimited: true},
+		"memory.current": {Value: 200 << 20},
+	})
+	f(FS{Root: "testdata/none_existing_folder"}, 0, "", nil)
+}
+
+func TestParseMountInfo(t *testing.T) {
+	mounts := parseMountInfo(`32 24 0:28 / /sys/fs/cgroup rw,relatime - tmpfs tmpfs rw,mode=755
+33 32 0:29 / /sys/fs/cgroup/cpu,cpuacct rw,relatime - cgroup cgroup rw,cpu,cpuacct
+41 32 0:37 / /sys/fs/cgroup/systemd rw,relatime - cgroup cgroup rw,xattr,name=systemd
+42 32 0:38 / /sys/fs/cgroup/unified rw,relatime shared:4 - cgroup2 cgroup2 rw,nsdelegate
+`)
+	if len(mounts) != 3 {
+		t.Fatalf("unexpected mounts: %+v", mounts)
+	}
+	if m := mounts[0]; m.Version != 1 || m.MountPoint != "/sys/fs/cgroup/cpu,cpuacct" ||
+		len(m.Controllers) != 2 || m.Controllers[0] != "cpu" || m.Controllers[1] != "cpuacct" {
+		t.Fatalf("unexpected mount: %+v", m)
+	}
+	if m := mounts[1]; m.Version != 1 || m.Controllers != nil {
+		t.Fatalf("unexpected mount: %+v", m)
+	}
+	if m := mounts[2]; m.Version != 2 || m.MountPoint != "/sys/fs/cgroup/unified" || m.Controllers != nil {
+		t.Fatalf("unexpected mount: %+v", m)
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	f := func(cgroupPath, mountRoot, want string) {
+		t.Helper()
+		if got := relativePath(cgroupPath, mountRoot); got != want {
+			t.Fatalf("unexpected relative path of %q in %q, got: %q, want %q", cgroupPath, mountRoot, got, want)
+		}
+	}
+	f("/docker/abc", "/", "/docker/abc")
+	f("/docker/abc", "/docker/abc", "/")
+	f("/docker/abc/worker", "/docker/abc", "/worker")
+	f("/docker/abcd", "/docker/abc", "/")
+}
@@ -1,22 +1,30 @@
 package cgroup
 
 import (
+	"errors"
 	"strconv"
 )
 
+var errNoMemoryCgroup = errors.New("cannot find the memory cgroup")
+
 // GetMemoryLimit returns cgroup memory limit
 func GetMemoryLimit() int64 {
+	return Default.GetMemoryLimit()
+}
+
+// GetMemoryLimit returns cgroup memory limit
+func (f FS) GetMemoryLimit() int64 {
 	// Try determining the amount of memory inside docker container.
 	// See https://stackoverflow.com/questions/42187085/check-mem-limit-within-a-docker-container
 	//
 	// Read memory limit according to https://unix.stackexchange.com/questions/242718/how-to-find-out-how-much-memory-lxc-container-is-allowed-to-consume
 	// This should properly determine the limit inside lxc container.
 	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/84
-	n, err := getMemStat("memory.limit_in_bytes")
+	n, err := f.getMemStat("memory.limit_in_bytes")
 	if err == nil {
 		return n
 	}
-	n, err = getMemStatV2("memory.max")
+	n, err = f.getMemStatV2("memory.max")
 	if err != nil {
 		return 0
 	}
@@ -24,11 +32,15 @@ func GetMemoryLimit() int64 {
 }
 
 func GetMemoryUsage() int64 {
-	n, err := getMemStat("memory.usage_in_bytes")
+	return Default.GetMemoryUsage()
+}
+
+func (f FS) GetMemoryUsage() int64 {
+	n, err := f.getMemStat("memory.usage_in_bytes")
 	if err == nil {
 		return n
 	}
-	n, err = getMemStatV2("memory.current")
+	n, err = f.getMemStatV2("memory.current")
 	if err != nil {
 		return 0
 	}
@@ -36,20 +48,30 @@ func GetMemoryUsage() int64 {
 }
 
 // see https://www.kernel.org/doc/Documentation/cgroup-v2.txt
-func getMemStatV2(statName string) (int64, error) {
+func (f FS) getMemStatV2(statName string) (int64, error) {
 	// See https: //www.kernel.org/doc/html/latest/admin-guide/cgroup-v2.html#memory-interface-files
-	return getStatGeneric(statName, "/sys/fs/cgroup", "/proc/self/cgroup", "")
+	return f.getStat(statName, "/sys/fs/cgroup", "")
 }
 
-func getMemStat(statName string) (int64, error) {
-	return getStatGeneric(statName, "/sys/fs/cgroup/memory", "/proc/self/cgroup", "memory")
+func (f FS) getMemStat(statName string) (int64, error) {
+	return f.getStat(statName, "/sys/fs/cgroup/memory", "memory")
 }
 
 // GetHierarchicalMemoryLimit returns hierarchical memory limit
 // https://www.kernel.org/doc/Documentation/cgroup-v1/memory.txt
 func GetHierarchicalMemoryLimit() int64 {
+	return Default.GetHierarchicalMemoryLimit()
+}
+
+// GetHierarchicalMemoryLimit returns hierarchical memory limit
+// https://www.kernel.org/doc/Documentation/cgroup-v1/memory.txt
+func (f FS) GetHierarchicalMemoryLimit() int64 {
 	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/699
-	n, err := getHierarchicalMemoryLimit("/sys/fs/cgroup/memory", "/proc/self/cgroup")
+	data, err := f.getFileContents("memory.stat", "/sys/fs/cgroup/memory", "memory")
+	if err != nil {
+		return 0
+	}
+	n, err := parseHierarchicalMemoryLimit(data)
 	if err != nil {
 		return 0
 	}
@@ -61,9 +83,50 @@ func getHierarchicalMemoryLimit(sysfsPrefix, cgroupPath string) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
+	return parseHierarchicalMemoryLimit(data)
+}
+
+func parseHierarchicalMemoryLimit(data string) (int64, error) {
 	memStat, err := grepFirstMatch(data, "hierarchical_memory_limit", 1, " ")
 	if err != nil {
 		return 0, err
 	}
 	return strconv.ParseInt(memStat, 10, 64)
 }
+
+// GetSwapLimit returns the cgroup swap limit, or -1 if it's unavailable or unlimited.
+// In cgroup v1, memory.memsw.limit_in_bytes limits memory+swap, the memory limit is subtracted from it.
+func (f FS) GetSwapLimit() int64 {
+	n, err := f.getMemStat("memory.memsw.limit_in_bytes")
+	if err == nil {
+		mem, err := f.getMemStat("memory.limit_in_bytes")
+		if err != nil || n < mem {
+			return -1
+		}
+		return n - mem
+	}
+	// memory.swap.max is "max" if unlimited
+	n, err = f.getMemStatV2("memory.swap.max")
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// GetSwapUsage returns the cgroup swap usage, or -1 if it's unavailable.
+// In cgroup v1, memory.memsw.usage_in_bytes is the usage of memory+swap, the memory usage is subtracted from it.
+func (f FS) GetSwapUsage() int64 {
+	n, err := f.getMemStat("memory.memsw.usage_in_bytes")
+	if err == nil {
+		mem, err := f.getMemStat("memory.usage_in_bytes")
+		if err != nil || n < mem {
+			return -1
+		}
+		return n - mem
+	}
+	n, err = f.getMemStatV2("memory.swap.current")
+	if err != nil {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,110 @@
+package cgroup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+type (
+	// PSI is the pressure stall information of a resource, see https://docs.kernel.org/accounting/psi.html
+	PSI struct {
+		// Some is the share of time in which at least some tasks are stalled on the resource.
+		Some PSIStats
+		// Full is the share of time in which all non-idle tasks are stalled on the resource simultaneously.
+		Full PSIStats
+	}
+
+	// PSIStats are the stall time percentages over 10s, 60s and 300s windows, and the total stall time in µs.
+	PSIStats struct {
+		Avg10  float64
+		Avg60  float64
+		Avg300 float64
+		Total  uint64
+	}
+)
+
+// GetMemoryPressure returns the memory pressure of the cgroup from memory.pressure, which requires cgroup v2
+func (f FS) GetMemoryPressure() (PSI, error) {
+	var (
+		data string
+		err  error
+	)
+	if f.pid() == "self" {
+		data, err = getFileContents("memory.pressure", f.Path("/sys/fs/cgroup"), f.Path("/proc/self/cgroup"), "")
+	} else {
+		data, err = f.readMemoryFile("memory.pressure")
+	}
+	if err != nil {
+		return PSI{}, err
+	}
+	return parsePSI(data)
+}
+
+// GetSystemMemoryPressure returns the memory pressure of the system from /proc/pressure/memory
+func (f FS) GetSystemMemoryPressure() (PSI, error) {
+	data, err := ioutil.ReadFile(f.Path("/proc/pressure/memory"))
+	if err != nil {
+		return PSI{}, err
+	}
+	return parsePSI(string(data))
+}
+
+// parsePSI parses the content of a pressure file, e.g.
+//
+//	some avg10=0.12 avg60=0.05 avg300=0.01 total=123456
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=2345
+func parsePSI(data string) (PSI, error) {
+	var (
+		psi   PSI
+		found bool
+	)
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var stats *PSIStats
+		switch fields[0] {
+		case "some":
+			stats = &psi.Some
+		case "full":
+			stats = &psi.Full
+		default:
+			continue
+		}
+		if err := parsePSIStats(fields[1:], stats); err != nil {
+			return PSI{}, fmt.Errorf("cannot parse %q: %v", line, err)
+		}
+		found = true
+	}
+	if !found {
+		return PSI{}, fmt.Errorf("cannot find pressure stats in %q", data)
+	}
+	return psi, nil
+}
+
+func parsePSIStats(fields []string, stats *PSIStats) error {
+	for _, field := range fields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid field %q", field)
+		}
+		var err error
+		switch kv[0] {
+		case "avg10":
+			stats.Avg10, err = strconv.ParseFloat(kv[1], 64)
+		case "avg60":
+			stats.Avg60, err = strconv.ParseFloat(kv[1], 64)
+		case "avg300":
+			stats.Avg300, err = strconv.ParseFloat(kv[1], 64)
+		case "total":
+			stats.Total, err = strconv.ParseUint(kv[1], 10, 64)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
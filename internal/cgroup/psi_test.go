@@ -0,0 +1,45 @@
+package cgroup
+
+import (
+	"testing"
+)
+
+func TestGetMemoryPressure(t *testing.T) {
+	psi, err := FS{Root: "testdata/inspect/v2"}.GetMemoryPressure()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := PSI{
+		Some: PSIStats{Avg10: 1.5, Avg60: 0.8, Avg300: 0.2, Total: 123456},
+		Full: PSIStats{Avg10: 0.5, Avg60: 0.1, Avg300: 0, Total: 2345},
+	}
+	if psi != want {
+		t.Fatalf("unexpected pressure, got: %+v, want %+v", psi, want)
+	}
+
+	psi, err = FS{Root: "testdata/inspect/v2"}.GetSystemMemoryPressure()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if psi != (PSI{}) {
+		t.Fatalf("unexpected pressure, got: %+v, want zero", psi)
+	}
+
+	// cgroup v1 has no memory.pressure
+	if _, err = (FS{Root: "testdata/inspect/v1"}).GetMemoryPressure(); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+func TestParsePSIFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		if _, err := parsePSI(data); err == nil {
+			t.Fatalf("expecting non-nil error for %q", data)
+		}
+	}
+	f("")
+	f("some avg10=x avg60=0.00 avg300=0.00 total=0")
+	f("some avg10")
+	f("other avg10=0.00")
+}
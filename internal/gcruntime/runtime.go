@@ -0,0 +1,72 @@
+// Package gcruntime abstracts the Go runtime and the environment the tuner depends on,
+// so that the tuning loop can be driven by a fake runtime in tests, see package gctunertest.
+package gcruntime
+
+import (
+	"time"
+
+	"github.com/fangwentong/gogctuner/internal/cgroup"
+	"github.com/fangwentong/gogctuner/internal/memory"
+)
+
+// Histogram is a float64 histogram of runtime/metrics.
+type Histogram = memory.Histogram
+
+type (
+	// Runtime is the Go runtime and the environment seen by the tuner.
+	Runtime interface {
+		// SetGCPercent sets GOGC and returns the previous setting, see debug.SetGCPercent.
+		SetGCPercent(percent int) int
+		// GCPercent returns the current GOGC setting.
+		GCPercent() int
+		// SetMemoryLimit sets the soft memory limit and returns the previous setting, see debug.SetMemoryLimit.
+		// It's a no-op returning math.MaxInt64 before go1.19.
+		SetMemoryLimit(limit int64) int64
+
+		// ReadMetric reads an uint64 metric by its runtime/metrics name.
+		ReadMetric(name string) (uint64, error)
+		// ReadFloat64Metric reads a float64 metric by its runtime/metrics name.
+		ReadFloat64Metric(name string) (float64, error)
+		// ReadHistogramMetric reads a float64 histogram metric by its runtime/metrics name.
+		ReadHistogramMetric(name string) (Histogram, error)
+		// LiveDatasetSize returns the live dataset size in bytes which required by calculating GOGC.
+		LiveDatasetSize() uint64
+
+		// NotifyGC calls f after every GC cycle, until f returns false.
+		NotifyGC(f func() bool)
+		// LastGC returns the time the last GC cycle completed, zero if no GC cycle has completed.
+		LastGC() time.Time
+		// GC runs a garbage collection, see runtime.GC.
+		GC()
+		// FreeOSMemory forces a garbage collection and returns as much memory to the OS as possible,
+		// see debug.FreeOSMemory.
+		FreeOSMemory()
+
+		// ReadMemoryLimit returns the memory limit of the process, e.g. the cgroup memory limit,
+		// or 0 if it cannot be determined.
+		ReadMemoryLimit() uint64
+		// ReadSwapLimit returns the swap available to the process, e.g. the cgroup swap limit, 0 if no swap.
+		ReadSwapLimit() uint64
+		// ReadMemoryPressure returns the memory pressure stall information of the cgroup,
+		// or of the system without cgroup v2.
+		ReadMemoryPressure() (cgroup.PSI, error)
+		// ReadMemoryEvents returns the event counters of the memory cgroup.
+		ReadMemoryEvents() (cgroup.MemoryEvents, error)
+		// ReadRSS returns the resident set size of the process, 0 if it cannot be determined.
+		ReadRSS() uint64
+
+		// Now returns the current time.
+		Now() time.Time
+		// NewTicker returns a new Ticker which ticks with the period d.
+		NewTicker(d time.Duration) Ticker
+
+		// Getenv retrieves the value of the environment variable named by the key.
+		Getenv(key string) string
+	}
+
+	// Ticker delivers ticks at intervals, see time.Ticker.
+	Ticker interface {
+		C() <-chan time.Time
+		Stop()
+	}
+)
@@ -0,0 +1,153 @@
+package gcruntime
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fangwentong/gogctuner/internal/cgroup"
+	"github.com/fangwentong/gogctuner/internal/memory"
+)
+
+// System is the Runtime backed by the real Go runtime and the operating system.
+var System Runtime = systemRuntime{}
+
+type systemRuntime struct{}
+
+var (
+	gcPercentMu  sync.Mutex
+	gcPercent    int
+	gcPercentSet bool
+)
+
+func (systemRuntime) SetGCPercent(percent int) int {
+	gcPercentMu.Lock()
+	defer gcPercentMu.Unlock()
+	gcPercent, gcPercentSet = percent, true
+	return debug.SetGCPercent(percent)
+}
+
+// GCPercent returns the last GOGC set by SetGCPercent, or the GOGC environment variable before that.
+// The runtime has no getter of GOGC, debug.SetGCPercent called by others is not seen.
+func (systemRuntime) GCPercent() int {
+	gcPercentMu.Lock()
+	defer gcPercentMu.Unlock()
+	if gcPercentSet {
+		return gcPercent
+	}
+	p := os.Getenv("GOGC")
+	if p == "off" {
+		return -1
+	}
+	if n, err := strconv.Atoi(p); err == nil {
+		return n
+	}
+	return 100
+}
+
+func (systemRuntime) SetMemoryLimit(limit int64) int64 {
+	return setMemoryLimit(limit)
+}
+
+func (systemRuntime) ReadMetric(name string) (uint64, error) {
+	return memory.ReadMetric(name)
+}
+
+func (systemRuntime) ReadFloat64Metric(name string) (float64, error) {
+	return memory.ReadFloat64Metric(name)
+}
+
+func (systemRuntime) ReadHistogramMetric(name string) (Histogram, error) {
+	return memory.ReadHistogramMetric(name)
+}
+
+func (systemRuntime) LiveDatasetSize() uint64 {
+	return memory.GetLiveDatasetSize()
+}
+
+// NotifyGC uses a finalizer to get notified of GC cycles: the finalizer of an unreachable object
+// runs after the GC cycle that found it, and it keeps the object alive for the next GC by setting itself again.
+func (systemRuntime) NotifyGC(f func() bool) {
+	var r = &ref{}
+	runtime.SetFinalizer(r, finalizer(f))
+	r = nil
+}
+
+func (systemRuntime) LastGC() time.Time {
+	var stats debug.GCStats
+	debug.ReadGCStats(&stats)
+	return stats.LastGC
+}
+
+func (systemRuntime) GC() {
+	runtime.GC()
+}
+
+func (systemRuntime) FreeOSMemory() {
+	debug.FreeOSMemory()
+}
+
+func finalizer(f func() bool) func(*ref) {
+	var fin func(*ref)
+	fin = func(r *ref) {
+		if f() {
+			runtime.SetFinalizer(r, fin) // Keep the object r alive for the next GC
+		}
+	}
+	return fin
+}
+
+type ref struct {
+	holder []byte
+}
+
+func (systemRuntime) ReadMemoryLimit() uint64 {
+	return memory.GetMemoryLimit()
+}
+
+func (systemRuntime) ReadSwapLimit() uint64 {
+	return memory.Reader{}.GetSwapLimit()
+}
+
+func (systemRuntime) ReadMemoryPressure() (cgroup.PSI, error) {
+	psi, err := cgroup.Default.GetMemoryPressure()
+	if err == nil {
+		return psi, nil
+	}
+	return cgroup.Default.GetSystemMemoryPressure()
+}
+
+func (systemRuntime) ReadMemoryEvents() (cgroup.MemoryEvents, error) {
+	return cgroup.Default.GetMemoryEvents()
+}
+
+func (systemRuntime) ReadRSS() uint64 {
+	return memory.Reader{}.GetRSS()
+}
+
+func (systemRuntime) Now() time.Time {
+	return time.Now()
+}
+
+func (systemRuntime) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	t *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t systemTicker) Stop() {
+	t.t.Stop()
+}
+
+func (systemRuntime) Getenv(key string) string {
+	return os.Getenv(key)
+}
@@ -0,0 +1,13 @@
+//go:build !go1.19
+// +build !go1.19
+
+package gcruntime
+
+import (
+	"math"
+)
+
+// setMemoryLimit is a no-op before go1.19
+func setMemoryLimit(limit int64) int64 {
+	return math.MaxInt64
+}
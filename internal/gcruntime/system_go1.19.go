@@ -0,0 +1,12 @@
+//go:build go1.19
+// +build go1.19
+
+package gcruntime
+
+import (
+	"runtime/debug"
+)
+
+func setMemoryLimit(limit int64) int64 {
+	return debug.SetMemoryLimit(limit)
+}
@@ -1,12 +1,110 @@
 package memory
 
+import (
+	"github.com/fangwentong/gogctuner/internal/cgroup"
+)
+
+// Sources of the memory limit, see SelectMemoryLimit
+const (
+	SourceCgroup       = "cgroup"
+	SourceHierarchical = "hierarchical"
+	SourceHost         = "host"
+)
+
+// Reader reads the memory limit of a process from the proc and cgroup filesystems located by FS,
+// FS is only used on linux.
+type Reader struct {
+	FS cgroup.FS
+}
+
 // GetMemoryLimit returns system memory limit
 // if cgroup is used, it returns cgroup memory limit
 func GetMemoryLimit() uint64 {
-	return sysTotalMemory()
+	return Reader{}.GetMemoryLimit()
 }
 
 // GetMemoryFree returns memory free
 func GetMemoryFree() uint64 {
-	return sysFreeMemory()
+	return Reader{}.GetMemoryFree()
+}
+
+// GetMemoryLimit returns system memory limit
+// if cgroup is used, it returns cgroup memory limit
+func (r Reader) GetMemoryLimit() uint64 {
+	return r.sysTotalMemory()
+}
+
+// GetMemoryFree returns memory free
+func (r Reader) GetMemoryFree() uint64 {
+	return r.sysFreeMemory()
+}
+
+// GetSwapLimit returns the swap available to the process, which is the cgroup swap limit
+// capped by the swap size of the host
+func (r Reader) GetSwapLimit() uint64 {
+	return r.sysSwapLimit()
+}
+
+// GetSwapUsage returns the swap usage of the process, the swap usage of the host is used without cgroup
+func (r Reader) GetSwapUsage() uint64 {
+	return r.sysSwapUsage()
+}
+
+// GetRSS returns the resident set size of the process, 0 if it cannot be determined
+func (r Reader) GetRSS() uint64 {
+	return r.sysRSS()
+}
+
+// HostTotalMemory returns the total memory of the host
+func (r Reader) HostTotalMemory() uint64 {
+	return r.hostTotalMemory()
+}
+
+// HostFreeMemory returns the free memory of the host
+func (r Reader) HostFreeMemory() uint64 {
+	return r.hostFreeMemory()
+}
+
+// Histogram is a float64 histogram of runtime/metrics, see metrics.Float64Histogram
+type Histogram struct {
+	// Counts are the counts of the buckets
+	Counts []uint64
+	// Buckets are the boundaries of the buckets, len(Buckets) == len(Counts)+1,
+	// the first and the last boundaries may be -Inf and +Inf
+	Buckets []float64
+}
+
+// ReadMetric reads an uint64 metric from runtime/metrics, it always fails before go1.16
+func ReadMetric(metricName string) (uint64, error) {
+	return readMetric(metricName)
+}
+
+// ReadFloat64Metric reads a float64 metric from runtime/metrics, it always fails before go1.16
+func ReadFloat64Metric(metricName string) (float64, error) {
+	return readFloat64Metric(metricName)
+}
+
+// ReadHistogramMetric reads a float64 histogram metric from runtime/metrics, it always fails before go1.16
+func ReadHistogramMetric(metricName string) (Histogram, error) {
+	return readHistogramMetric(metricName)
+}
+
+// SelectMemoryLimit returns the effective memory limit and its source, the cgroup limit is used if it's valid,
+// otherwise the hierarchical limit, and the host total memory at last.
+// A limit is valid if it's positive and not larger than the host total memory.
+// hierarchicalLimit is only called if the cgroup limit is invalid.
+func SelectMemoryLimit(hostTotal uint64, cgroupLimit int64, hierarchicalLimit func() int64) (uint64, string) {
+	if isValidLimit(cgroupLimit, hostTotal) {
+		return uint64(cgroupLimit), SourceCgroup
+	}
+	// Try reading hierarchical memory limit.
+	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/699
+	if mem := hierarchicalLimit(); isValidLimit(mem, hostTotal) {
+		return uint64(mem), SourceHierarchical
+	}
+	return hostTotal, SourceHost
+}
+
+func isValidLimit(mem int64, hostTotal uint64) bool {
+	return mem > 0 && int64(int(mem)) == mem && uint64(mem) <= hostTotal
 }
@@ -7,10 +7,32 @@ import (
 	"github.com/pbnjay/memory"
 )
 
-func sysTotalMemory() uint64 {
+func (r Reader) sysTotalMemory() uint64 {
 	return memory.TotalMemory()
 }
 
-func sysFreeMemory() uint64 {
+func (r Reader) sysFreeMemory() uint64 {
 	return memory.FreeMemory()
 }
+
+func (r Reader) hostTotalMemory() uint64 {
+	return memory.TotalMemory()
+}
+
+func (r Reader) hostFreeMemory() uint64 {
+	return memory.FreeMemory()
+}
+
+// swap is not supported on this OS
+func (r Reader) sysSwapLimit() uint64 {
+	return 0
+}
+
+func (r Reader) sysSwapUsage() uint64 {
+	return 0
+}
+
+// RSS is not supported on this OS
+func (r Reader) sysRSS() uint64 {
+	return 0
+}
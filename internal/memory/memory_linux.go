@@ -1,33 +1,101 @@
 package memory
 
 import (
+	"bufio"
 	"fmt"
-	"github.com/fangwentong/gogctuner/internal/cgroup"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
 	"github.com/pbnjay/memory"
 )
 
-func sysTotalMemory() uint64 {
-	totalMem := memory.TotalMemory()
+func (r Reader) sysTotalMemory() uint64 {
+	totalMem := r.hostTotalMemory()
 	if totalMem == 0 {
-		panic(fmt.Sprintf("FATAL: cannot determine system memory"))
-	}
-	mem := cgroup.GetMemoryLimit()
-	if mem <= 0 || int64(int(mem)) != mem || uint64(mem) > totalMem {
-		// Try reading hierarchical memory limit.
-		// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/699
-		mem = cgroup.GetHierarchicalMemoryLimit()
-		if mem <= 0 || int64(int(mem)) != mem || uint64(mem) > totalMem {
-			return totalMem
+		if r.FS.Root != "" {
+			return 0
 		}
+		panic(fmt.Sprintf("FATAL: cannot determine system memory"))
 	}
-	return uint64(mem)
+	limit, _ := SelectMemoryLimit(totalMem, r.FS.GetMemoryLimit(), r.FS.GetHierarchicalMemoryLimit)
+	return limit
 }
 
-func sysFreeMemory() uint64 {
-	total := sysTotalMemory()
-	usage := cgroup.GetMemoryUsage()
+func (r Reader) sysFreeMemory() uint64 {
+	total := r.sysTotalMemory()
+	usage := r.FS.GetMemoryUsage()
 	if usage <= 0 || int64(int(usage)) != usage || uint64(usage) > total {
-		return memory.FreeMemory()
+		return r.hostFreeMemory()
 	}
 	return total - uint64(usage)
 }
+
+func (r Reader) sysSwapLimit() uint64 {
+	hostSwap := readMemInfo(r.FS.Path("/proc/meminfo"), "SwapTotal")
+	if limit := r.FS.GetSwapLimit(); limit >= 0 && uint64(limit) < hostSwap {
+		return uint64(limit)
+	}
+	return hostSwap
+}
+
+func (r Reader) sysSwapUsage() uint64 {
+	if usage := r.FS.GetSwapUsage(); usage >= 0 {
+		return uint64(usage)
+	}
+	total, free := readMemInfo(r.FS.Path("/proc/meminfo"), "SwapTotal"), readMemInfo(r.FS.Path("/proc/meminfo"), "SwapFree")
+	if free > total {
+		return 0
+	}
+	return total - free
+}
+
+// sysRSS reads the resident set size from smaps_rollup, which is more accurate than VmRSS of status
+// on the kernels with per-CPU RSS counters, status is used before linux 4.14
+func (r Reader) sysRSS() uint64 {
+	if rss := readMemInfo(path.Join(r.FS.ProcDir(), "smaps_rollup"), "Rss"); rss > 0 {
+		return rss
+	}
+	return readMemInfo(path.Join(r.FS.ProcDir(), "status"), "VmRSS")
+}
+
+// hostTotalMemory returns the total memory of the host, it's read from /proc/meminfo under the FS root if specified
+func (r Reader) hostTotalMemory() uint64 {
+	if r.FS.Root == "" {
+		return memory.TotalMemory()
+	}
+	return readMemInfo(r.FS.Path("/proc/meminfo"), "MemTotal")
+}
+
+func (r Reader) hostFreeMemory() uint64 {
+	if r.FS.Root == "" {
+		return memory.FreeMemory()
+	}
+	return readMemInfo(r.FS.Path("/proc/meminfo"), "MemFree")
+}
+
+// readMemInfo reads a field of /proc/meminfo in bytes, e.g. "MemTotal:       16314060 kB"
+func readMemInfo(path, field string) uint64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != field+":" {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0
+		}
+		if len(fields) > 2 && fields[2] == "kB" {
+			n <<= 10
+		}
+		return n
+	}
+	return 0
+}
@@ -2,6 +2,7 @@ package memory_test
 
 import (
 	"fmt"
+	"github.com/fangwentong/gogctuner/internal/cgroup"
 	"github.com/fangwentong/gogctuner/internal/memory"
 	"log"
 	"testing"
@@ -41,3 +42,67 @@ func printMemorySize(bytes uint64) string {
 
 	return fmt.Sprintf("%.2f%s", value, unit)
 }
+
+func TestSelectMemoryLimit(t *testing.T) {
+	f := func(cgroupLimit, hierarchicalLimit int64, wantLimit uint64, wantSource string) {
+		t.Helper()
+		limit, source := memory.SelectMemoryLimit(1<<30, cgroupLimit, func() int64 { return hierarchicalLimit })
+		if limit != wantLimit || source != wantSource {
+			t.Fatalf("unexpected limit, got: %d (%s), want %d (%s)", limit, source, wantLimit, wantSource)
+		}
+	}
+	f(512<<20, 256<<20, 512<<20, memory.SourceCgroup)
+	f(0, 256<<20, 256<<20, memory.SourceHierarchical)
+	f(9223372036854771712, 256<<20, 256<<20, memory.SourceHierarchical)
+	f(9223372036854771712, 9223372036854771712, 1<<30, memory.SourceHost)
+	f(0, 0, 1<<30, memory.SourceHost)
+}
+
+func TestReader(t *testing.T) {
+	f := func(fs cgroup.FS, wantLimit, wantFree uint64) {
+		t.Helper()
+		r := memory.Reader{FS: fs}
+		if got := r.GetMemoryLimit(); got != wantLimit {
+			t.Fatalf("unexpected memory limit, got: %d, want %d", got, wantLimit)
+		}
+		if got := r.GetMemoryFree(); got != wantFree {
+			t.Fatalf("unexpected free memory, got: %d, want %d", got, wantFree)
+		}
+	}
+	f(cgroup.FS{Root: "../cgroup/testdata/inspect/v1"}, 1<<30, 1<<30-100<<20)
+	f(cgroup.FS{Root: "../cgroup/testdata/inspect/v1", PID: "1234"}, 256<<20, 256<<20-50<<20)
+	f(cgroup.FS{Root: "../cgroup/testdata/inspect/v2"}, 2<<30, 2<<30-200<<20)
+	f(cgroup.FS{Root: "../cgroup/testdata/none_existing_folder"}, 0, 0)
+}
+
+func TestReaderSwap(t *testing.T) {
+	f := func(fs cgroup.FS, wantLimit, wantUsage uint64) {
+		t.Helper()
+		r := memory.Reader{FS: fs}
+		if got := r.GetSwapLimit(); got != wantLimit {
+			t.Fatalf("unexpected swap limit, got: %d, want %d", got, wantLimit)
+		}
+		if got := r.GetSwapUsage(); got != wantUsage {
+			t.Fatalf("unexpected swap usage, got: %d, want %d", got, wantUsage)
+		}
+	}
+	f(cgroup.FS{Root: "../cgroup/testdata/inspect/v1"}, 1<<30, 10<<20)
+	// no cgroup swap stats, use the host swap
+	f(cgroup.FS{Root: "../cgroup/testdata/inspect/v1", PID: "1234"}, 2<<30, 1<<30)
+	// unlimited
+	f(cgroup.FS{Root: "../cgroup/testdata/inspect/v2"}, 2<<30, 10<<20)
+	f(cgroup.FS{Root: "../cgroup/testdata/none_existing_folder"}, 0, 0)
+}
+
+func TestReaderRSS(t *testing.T) {
+	f := func(fs cgroup.FS, want uint64) {
+		t.Helper()
+		if got := (memory.Reader{FS: fs}).GetRSS(); got != want {
+			t.Fatalf("unexpected rss, got: %d, want %d", got, want)
+		}
+	}
+	f(cgroup.FS{Root: "../cgroup/testdata/inspect/v1"}, 300<<20)
+	// no smaps_rollup, use status
+	f(cgroup.FS{Root: "../cgroup/testdata/inspect/v1", PID: "1234"}, 100<<20)
+	f(cgroup.FS{Root: "../cgroup/testdata/none_existing_folder"}, 0)
+}
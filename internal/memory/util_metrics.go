@@ -31,3 +31,22 @@ func readMetric(metricName string) (uint64, error) {
 	freeBytes := sample[0].Value.Uint64()
 	return freeBytes, nil
 }
+
+func readFloat64Metric(metricName string) (float64, error) {
+	sample := []metrics.Sample{{Name: metricName}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindFloat64 {
+		return 0, fmt.Errorf("metric %q no longer supported", metricName)
+	}
+	return sample[0].Value.Float64(), nil
+}
+
+func readHistogramMetric(metricName string) (Histogram, error) {
+	sample := []metrics.Sample{{Name: metricName}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindFloat64Histogram {
+		return Histogram{}, fmt.Errorf("metric %q no longer supported", metricName)
+	}
+	h := sample[0].Value.Float64Histogram()
+	return Histogram{Counts: h.Counts, Buckets: h.Buckets}, nil
+}
@@ -0,0 +1,22 @@
+//go:build !go1.16
+// +build !go1.16
+
+package memory
+
+import (
+	"errors"
+)
+
+var errMetricsUnsupported = errors.New("runtime/metrics is not supported before go1.16")
+
+func readMetric(metricName string) (uint64, error) {
+	return 0, errMetricsUnsupported
+}
+
+func readFloat64Metric(metricName string) (float64, error) {
+	return 0, errMetricsUnsupported
+}
+
+func readHistogramMetric(metricName string) (Histogram, error) {
+	return Histogram{}, errMetricsUnsupported
+}
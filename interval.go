@@ -0,0 +1,64 @@
+package gogctuner
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	gcCyclesMetric = "/gc/cycles/total:gc-cycles"
+
+	// periodicCheckInterval is the interval of the re-evaluations not triggered by GC cycles
+	periodicCheckInterval = 10 * time.Second
+	// gcIntervalCheckPeriod is the period of checking Config.MaxGCInterval
+	gcIntervalCheckPeriod = time.Second
+)
+
+// gcIntervalState is the state of the MaxGCInterval checks
+type gcIntervalState struct {
+	numGC  uint64
+	lastGC time.Time
+}
+
+// watchGCInterval forces a GC cycle if no GC cycle has happened within Config.MaxGCInterval
+func (a *adaptiveGCHandler) watchGCInterval() {
+	t := a.rt.NewTicker(gcIntervalCheckPeriod)
+	defer t.Stop()
+	s := gcIntervalState{lastGC: a.rt.Now()}
+	for {
+		select {
+		case <-t.C():
+		case <-a.done:
+			return
+		}
+		a.checkGCInterval(&s)
+	}
+}
+
+func (a *adaptiveGCHandler) checkGCInterval(s *gcIntervalState) {
+	now := a.rt.Now()
+	if numGC := a.readNumGC(); numGC != s.numGC {
+		s.numGC, s.lastGC = numGC, now
+		return
+	}
+	config, _ := a.appliedConfig.Load().(Config)
+	if config.MaxGCInterval <= 0 || now.Sub(s.lastGC) < config.MaxGCInterval {
+		return
+	}
+
+	a.logger.Logf("gctuner: no GC cycle within %v, force a GC cycle", config.MaxGCInterval)
+	a.rt.GC()
+	s.numGC, s.lastGC = a.readNumGC(), a.rt.Now()
+	a.updateStatus(func(st *Status) {
+		st.ForcedGCs++
+		st.LastForcedGC = now
+	})
+}
+
+// readNumGC returns the number of GC cycles from runtime/metrics, or from the GC hook before go1.16
+func (a *adaptiveGCHandler) readNumGC() uint64 {
+	if n, err := a.rt.ReadMetric(gcCyclesMetric); err == nil {
+		return n
+	}
+	return uint64(atomic.LoadUint32(&a.numGC))
+}
@@ -0,0 +1,83 @@
+package gogctuner
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestMaxGCInterval(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	h, _ := newTestHandler(rt, staticConfigurator{config: Config{GOGC: -1, MaxGCInterval: time.Minute}})
+	h.checkAndSetNextGCConfig()
+	h.installGCHook()
+
+	s := gcIntervalState{lastGC: rt.Now()}
+	f := func(d time.Duration, gc bool, numGC int) {
+		t.Helper()
+		rt.Advance(d)
+		if gc {
+			rt.TriggerGC()
+		}
+		h.checkGCInterval(&s)
+		if rt.NumGC() != numGC {
+			t.Fatalf("unexpected number of GC cycles, got: %d, want %d", rt.NumGC(), numGC)
+		}
+	}
+
+	f(30*time.Second, false, 0)
+	f(30*time.Second, false, 1) // forced
+	f(50*time.Second, true, 2)
+	f(50*time.Second, false, 2)
+	f(10*time.Second, false, 3) // forced
+	if st := h.Status(); st.ForcedGCs != 2 || !st.LastForcedGC.Equal(rt.Now()) {
+		t.Fatalf("unexpected forced GCs, got: %d at %v", st.ForcedGCs, st.LastForcedGC)
+	}
+
+	// the GC cycles are read from runtime/metrics if available
+	rt.SetMetric(gcCyclesMetric, 100)
+	f(10*time.Second, false, 3)
+	f(time.Minute, false, 4)
+}
+
+func TestPeriodicCheck(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	configurator := &silentConfigurator{config: Config{GOGC: 100}}
+	h, _ := newTestHandler(rt, configurator)
+	h.Start()
+	defer h.Stop()
+
+	// no GC cycle and no config update event
+	configurator.set(Config{GOGC: 200})
+	for i := 0; i < 1000 && rt.GCPercent() != 200; i++ {
+		rt.Advance(periodicCheckInterval)
+		time.Sleep(time.Millisecond)
+	}
+	if rt.GCPercent() != 200 {
+		t.Fatalf("unexpected GOGC, got: %d, want 200", rt.GCPercent())
+	}
+}
+
+// silentConfigurator is a configurator without config update events
+type silentConfigurator struct {
+	mu     sync.Mutex
+	config Config
+}
+
+func (c *silentConfigurator) GetConfig() (Config, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.config, nil
+}
+
+func (c *silentConfigurator) Updates() <-chan interface{} {
+	return nil
+}
+
+func (c *silentConfigurator) set(config Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config = config
+}
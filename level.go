@@ -0,0 +1,179 @@
+package gogctuner
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultElevatedWatermark = 70
+	defaultCriticalWatermark = 90
+	// pressureLevelHysteresis is the percentage points below the watermark to leave a pressure level
+	pressureLevelHysteresis = 5
+)
+
+// PressureLevel is the heap pressure level, computed from the live heap plus the off-heap memory
+// in the effective limit, see Config.ElevatedWatermark
+type PressureLevel int
+
+const (
+	PressureNormal PressureLevel = iota
+	PressureElevated
+	PressureCritical
+)
+
+func (l PressureLevel) String() string {
+	switch l {
+	case PressureNormal:
+		return "normal"
+	case PressureElevated:
+		return "elevated"
+	case PressureCritical:
+		return "critical"
+	}
+	return fmt.Sprintf("PressureLevel(%d)", int(l))
+}
+
+// PressureInfo describes a heap pressure level transition
+type PressureInfo struct {
+	Level    PressureLevel
+	Previous PressureLevel
+	// LiveHeap is the live heap size after the last GC
+	LiveHeap uint64
+	// OffHeap is the sum of the sources registered by RegisterOffHeapSource
+	OffHeap uint64
+	// Reserved is the sum of the reservations, see Reserve
+	Reserved uint64
+	// Limit is the effective limit, i.e. MaxRAMPercentage of the memory limit
+	Limit uint64
+	// Percentage is the percentage of LiveHeap plus OffHeap plus Reserved in Limit
+	Percentage float64
+	// BytesToFree is the suggested number of bytes to free to get back to PressureNormal
+	BytesToFree uint64
+	// Since is the time of the transition to Level
+	Since time.Time
+}
+
+// pressureCallback is a callback registered by OnPressure, it's compared by the pointer on unregistering
+type pressureCallback struct {
+	f func(PressureInfo)
+}
+
+var pressureCallbacks = struct {
+	sync.Mutex
+	callbacks map[PressureLevel][]*pressureCallback
+}{callbacks: make(map[PressureLevel][]*pressureCallback)}
+
+// OnPressure registers f to be called when the heap pressure level transitions to level, so that caches can shed
+// entries before the GC starts thrashing, e.g.
+//
+//	gogctuner.OnPressure(gogctuner.PressureCritical, func(info gogctuner.PressureInfo) {
+//		cache.Evict(info.BytesToFree)
+//	})
+//
+// The level is evaluated after every GC cycle, f is called synchronously by the tuner and must not block.
+// The returned unregister removes f, it's safe to call multiple times.
+func OnPressure(level PressureLevel, f func(PressureInfo)) (unregister func()) {
+	c := &pressureCallback{f: f}
+	pressureCallbacks.Lock()
+	pressureCallbacks.callbacks[level] = append(pressureCallbacks.callbacks[level], c)
+	pressureCallbacks.Unlock()
+	return func() {
+		pressureCallbacks.Lock()
+		defer pressureCallbacks.Unlock()
+		callbacks := pressureCallbacks.callbacks[level]
+		for i, other := range callbacks {
+			if other == c {
+				// copy on write, the callbacks may be being called without the lock
+				left := make([]*pressureCallback, 0, len(callbacks)-1)
+				pressureCallbacks.callbacks[level] = append(append(left, callbacks[:i]...), callbacks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// watermarks returns the watermarks with the defaults
+func (c *Config) watermarks() (elevated, critical float64) {
+	elevated, critical = c.ElevatedWatermark, c.CriticalWatermark
+	if elevated == 0 {
+		elevated = defaultElevatedWatermark
+	}
+	if critical == 0 {
+		critical = defaultCriticalWatermark
+	}
+	return elevated, critical
+}
+
+// updatePressureLevel evaluates the heap pressure level, and calls the callbacks on transitions
+func (a *adaptiveGCHandler) updatePressureLevel(config Config) {
+	memLimit, err := getMemoryLimit(a.rt, config)
+	if err != nil {
+		return
+	}
+	maxRAMPercentage := config.MaxRAMPercentage
+	if maxRAMPercentage <= 0 {
+		maxRAMPercentage = 100
+	}
+
+	elevated, critical := config.watermarks()
+	info := PressureInfo{LiveHeap: a.rt.LiveDatasetSize(), Limit: uint64(maxRAMPercentage / 100 * float64(memLimit))}
+	a.updateStatus(func(s *Status) {
+		for _, source := range s.OffHeap {
+			info.OffHeap += source.Bytes
+		}
+		info.Previous, info.Since = s.HeapPressure.Level, s.HeapPressure.Since
+	})
+	info.Reserved = a.reservedBytes()
+	used := float64(info.LiveHeap + info.OffHeap + info.Reserved)
+	info.Percentage = 100 * used / float64(info.Limit)
+	info.Level = nextPressureLevel(info.Previous, info.Percentage, elevated, critical)
+	if info.Level != PressureNormal {
+		info.BytesToFree = uint64(math.Max(used-(elevated-pressureLevelHysteresis)/100*float64(info.Limit), 0))
+	}
+	if info.Level != info.Previous || info.Since.IsZero() {
+		info.Since = a.rt.Now()
+	}
+	a.updateStatus(func(s *Status) {
+		s.HeapPressure = info
+	})
+	if info.Level == info.Previous {
+		return
+	}
+
+	a.logger.Logf("gctuner: heap pressure level %s -> %s, %.2f%% of %s", info.Previous, info.Level,
+		info.Percentage, printMemorySize(info.Limit))
+	pressureCallbacks.Lock()
+	callbacks := pressureCallbacks.callbacks[info.Level]
+	pressureCallbacks.Unlock()
+	for _, c := range callbacks {
+		f := c.f
+		a.withRecover(func() {
+			f(info)
+		})()
+	}
+}
+
+// nextPressureLevel returns the level of the percentage, a level is left once the percentage drops
+// pressureLevelHysteresis below its watermark
+func nextPressureLevel(current PressureLevel, percentage, elevated, critical float64) PressureLevel {
+	levelOf := func(p float64) PressureLevel {
+		switch {
+		case p >= critical:
+			return PressureCritical
+		case p >= elevated:
+			return PressureElevated
+		}
+		return PressureNormal
+	}
+	level := levelOf(percentage)
+	if level >= current {
+		return level
+	}
+	if level = levelOf(percentage + pressureLevelHysteresis); level > current {
+		return current
+	}
+	return level
+}
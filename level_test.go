@@ -0,0 +1,87 @@
+package gogctuner
+
+import (
+	"testing"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestNextPressureLevel(t *testing.T) {
+	f := func(current PressureLevel, percentage float64, want PressureLevel) {
+		t.Helper()
+		if got := nextPressureLevel(current, percentage, 70, 90); got != want {
+			t.Fatalf("unexpected level from %s at %.0f%%, got: %s, want %s", current, percentage, got, want)
+		}
+	}
+	f(PressureNormal, 50, PressureNormal)
+	f(PressureNormal, 70, PressureElevated)
+	f(PressureNormal, 95, PressureCritical)
+	f(PressureElevated, 80, PressureElevated)
+	f(PressureElevated, 66, PressureElevated)
+	f(PressureElevated, 64, PressureNormal)
+	f(PressureCritical, 86, PressureCritical)
+	f(PressureCritical, 84, PressureElevated)
+	f(PressureCritical, 60, PressureNormal)
+}
+
+func TestHeapPressureCallbacks(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1250 << 20)
+	h, _ := newTestHandler(rt, staticConfigurator{config: Config{MaxRAMPercentage: 80}})
+
+	var infos []PressureInfo
+	var unregisters []func()
+	for _, level := range []PressureLevel{PressureNormal, PressureElevated, PressureCritical} {
+		unregisters = append(unregisters, OnPressure(level, func(info PressureInfo) {
+			infos = append(infos, info)
+		}))
+	}
+	offHeap := uint64(0)
+	RegisterOffHeapSource("test", func() uint64 { return offHeap })
+	defer RegisterOffHeapSource("test", nil)
+
+	f := func(liveHeap uint64, level PressureLevel, bytesToFree uint64, calls int) {
+		t.Helper()
+		rt.SetLiveHeap(liveHeap)
+		h.checkAndSetNextGCConfig()
+		info := h.Status().HeapPressure
+		if info.Level != level || info.BytesToFree != bytesToFree || info.Limit != 1000<<20 {
+			t.Fatalf("unexpected pressure with live heap %d, got: %+v, want level %s, bytes to free %d",
+				liveHeap, info, level, bytesToFree)
+		}
+		if len(infos) != calls {
+			t.Fatalf("unexpected number of callback calls, got: %d, want %d", len(infos), calls)
+		}
+	}
+
+	f(500<<20, PressureNormal, 0, 0)
+	f(700<<20, PressureElevated, 50<<20, 1)
+	f(600<<20, PressureNormal, 0, 2)
+	offHeap = 300 << 20
+	f(650<<20, PressureCritical, 300<<20, 3)
+	f(560<<20, PressureCritical, 210<<20, 3)
+	if infos[2].Previous != PressureNormal || infos[2].Level != PressureCritical || infos[2].OffHeap != 300<<20 {
+		t.Fatalf("unexpected callback info, got: %+v", infos[2])
+	}
+
+	// the unregistered callbacks are not called
+	for _, unregister := range unregisters {
+		unregister()
+		unregister()
+	}
+	offHeap = 0
+	f(500<<20, PressureNormal, 0, 3)
+}
+
+func TestWatermarksConfig(t *testing.T) {
+	f := func(config Config, valid bool) {
+		t.Helper()
+		if err := config.CheckValid(); (err == nil) != valid {
+			t.Fatalf("unexpected validity of %+v, got: %v, want %v", config, err, valid)
+		}
+	}
+	f(Config{ElevatedWatermark: 80}, true)
+	f(Config{ElevatedWatermark: 95}, false)
+	f(Config{ElevatedWatermark: 95, CriticalWatermark: 98}, true)
+	f(Config{CriticalWatermark: 101}, false)
+}
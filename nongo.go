@@ -0,0 +1,134 @@
+package gogctuner
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	goMemoryTotalMetric    = "/memory/classes/total:bytes"
+	goMemoryReleasedMetric = "/memory/classes/heap/released:bytes"
+
+	// nonGoMemorySmoothing is the weight of the latest sample in the moving average of the non-Go memory
+	nonGoMemorySmoothing = 0.3
+	// nonGoMemoryGranularity rounds the non-Go memory down, so that the GC parameters are not reset on every GC
+	nonGoMemoryGranularity = 1 << 20
+)
+
+var errNoRSS = errors.New("gctuner: failed to get the rss of the process")
+
+// NonGoMemory is the memory of the process not managed by the Go runtime, see Config.AccountNonGoMemory
+type NonGoMemory struct {
+	// RSS is the resident set size of the process
+	RSS uint64
+	// GoMemory is the memory mapped by the Go runtime and not released to the OS
+	GoMemory uint64
+	// Bytes is the smoothed non-Go memory subtracted from the budget of the Go runtime
+	Bytes uint64
+	// Error is the error of the latest measurement
+	Error string
+}
+
+// applyNonGoMemory lowers MaxRAMPercentage by the share of the non-Go memory and the reservations
+// in the memory limit, the non-Go memory is the larger one of the measured non-Go memory and the sum of
+// the off-heap sources, as the measured non-Go memory includes the off-heap memory.
+func (a *adaptiveGCHandler) applyNonGoMemory(config Config) Config {
+	nonGo := a.readOffHeapSources()
+	if config.AccountNonGoMemory && config.MaxRAMPercentage > 0 {
+		if measured := a.nonGoMemory(); measured > nonGo {
+			nonGo = measured
+		}
+	} else {
+		a.updateStatus(func(s *Status) {
+			s.NonGoMemory = NonGoMemory{}
+		})
+	}
+	nonGo += a.reservedBytes()
+	if config.MaxRAMPercentage <= 0 || nonGo == 0 {
+		return config
+	}
+	limit, err := getMemoryLimit(a.rt, config)
+	if err != nil {
+		return config
+	}
+	// keep a minimal heap to avoid thrashing the GC
+	minPercentage := math.Min(100*config.heapFloor()/float64(limit), config.MaxRAMPercentage)
+	config.MaxRAMPercentage = math.Max(config.MaxRAMPercentage-100*float64(nonGo)/float64(limit), minPercentage)
+	return config
+}
+
+// nonGoMemory returns the latest measurement of the non-Go memory, the memory is measured at once
+// if it's not measured yet, and by sampleNonGoMemory on the periodic re-evaluations afterwards,
+// so that the RSS (smaps_rollup) is not read and parsed on every GC
+func (a *adaptiveGCHandler) nonGoMemory() uint64 {
+	a.statusMu.Lock()
+	m := a.status.NonGoMemory
+	a.statusMu.Unlock()
+	if m.RSS == 0 && m.Error == "" {
+		return a.measureNonGoMemory()
+	}
+	return m.Bytes
+}
+
+// sampleNonGoMemory measures the non-Go memory if it's accounted by the applied config
+func (a *adaptiveGCHandler) sampleNonGoMemory() {
+	if config, _ := a.appliedConfig.Load().(Config); config.AccountNonGoMemory {
+		a.measureNonGoMemory()
+	}
+}
+
+// measureNonGoMemory returns the moving average of the non-Go memory, the last average is kept on errors
+func (a *adaptiveGCHandler) measureNonGoMemory() uint64 {
+	rss := a.rt.ReadRSS()
+	goMemory, err := a.readGoMemory()
+	if err == nil && rss == 0 {
+		err = errNoRSS
+	}
+
+	var nonGo uint64
+	a.updateStatus(func(s *Status) {
+		m := &s.NonGoMemory
+		if err != nil {
+			m.Error = err.Error()
+			nonGo = m.Bytes
+			return
+		}
+		var sample float64
+		if rss > goMemory {
+			sample = float64(rss - goMemory)
+		}
+		avg := sample
+		if m.RSS != 0 { // not the first sample
+			avg = nonGoMemorySmoothing*sample + (1-nonGoMemorySmoothing)*float64(m.Bytes)
+		}
+		m.RSS, m.GoMemory, m.Error = rss, goMemory, ""
+		m.Bytes = uint64(avg) &^ (nonGoMemoryGranularity - 1)
+		nonGo = m.Bytes
+	})
+	return nonGo
+}
+
+// readGoMemory returns the memory mapped by the Go runtime and not released to the OS
+func (a *adaptiveGCHandler) readGoMemory() (uint64, error) {
+	total, err := a.rt.ReadMetric(goMemoryTotalMetric)
+	if err != nil {
+		return 0, err
+	}
+	released, err := a.rt.ReadMetric(goMemoryReleasedMetric)
+	if err != nil {
+		return 0, err
+	}
+	if released > total {
+		return 0, nil
+	}
+	return total - released, nil
+}
+
+// readUsage returns the memory usage of the process, i.e. the RSS, or the memory of the Go runtime without RSS
+func (a *adaptiveGCHandler) readUsage() uint64 {
+	usage := a.rt.ReadRSS()
+	if usage == 0 {
+		usage, _ = a.readGoMemory()
+	}
+	return usage
+}
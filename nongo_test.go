@@ -0,0 +1,49 @@
+package gogctuner
+
+import (
+	"testing"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestNonGoMemory(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1000 << 20)
+	h, _ := newTestHandler(rt, staticConfigurator{config: Config{MaxRAMPercentage: 80, AccountNonGoMemory: true}})
+
+	f := func(rss, goTotal, goReleased, nonGo uint64, maxRAMPercentage float64) {
+		t.Helper()
+		rt.SetRSS(rss)
+		rt.SetMetric(goMemoryTotalMetric, goTotal)
+		rt.SetMetric(goMemoryReleasedMetric, goReleased)
+		h.sampleNonGoMemory()
+		h.checkAndSetNextGCConfig()
+		s := h.Status()
+		if s.NonGoMemory.Bytes != nonGo {
+			t.Fatalf("unexpected non-Go memory, got: %d, want %d", s.NonGoMemory.Bytes, nonGo)
+		}
+		if p := s.AppliedConfig.MaxRAMPercentage; p != maxRAMPercentage {
+			t.Fatalf("unexpected applied MaxRAMPercentage, got: %.2f, want %.2f", p, maxRAMPercentage)
+		}
+	}
+
+	f(400<<20, 250<<20, 50<<20, 200<<20, 60)
+	f(200<<20, 250<<20, 50<<20, 140<<20, 66) // smoothed
+	f(1200<<20, 300<<20, 0, 368<<20, 43.2)   // 0.3*900MB+0.7*140MB
+	f(2000<<20, 0, 0, 857<<20, 0.4)          // keep the minimal heap
+	f(100<<20, 200<<20, 0, 599<<20, 20.1)    // no negative samples
+
+	// the memory is only measured by the periodic samples
+	rt.SetRSS(400 << 20)
+	h.checkAndSetNextGCConfig()
+	if s := h.Status(); s.NonGoMemory.RSS != 100<<20 || s.NonGoMemory.Bytes != 599<<20 {
+		t.Fatalf("unexpected non-Go memory measured without a sample, got: %+v", s.NonGoMemory)
+	}
+
+	rt.SetRSS(0)
+	h.sampleNonGoMemory()
+	h.checkAndSetNextGCConfig()
+	if s := h.Status(); s.NonGoMemory.Error == "" || s.NonGoMemory.Bytes != 599<<20 {
+		t.Fatalf("expecting the last measurement with an error, got: %+v", s.NonGoMemory)
+	}
+}
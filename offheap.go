@@ -0,0 +1,57 @@
+package gogctuner
+
+import (
+	"sort"
+	"sync"
+)
+
+// OffHeapSource is the memory held outside the Go heap by a source registered by RegisterOffHeapSource
+type OffHeapSource struct {
+	Name  string
+	Bytes uint64
+}
+
+var offHeapSources = struct {
+	sync.Mutex
+	sources map[string]func() uint64
+}{sources: make(map[string]func() uint64)}
+
+// RegisterOffHeapSource registers a source of the native memory held outside the Go heap, e.g. the block cache
+// of an embedded storage engine or a cgo-based codec pool. The tuner sums up the sources and subtracts them from
+// the budget of the Go runtime before computing the soft memory limit or GOGC.
+// Registering a name again replaces its source, and a nil f unregisters it.
+// f is called on every GC cycle, it should be cheap and must not block.
+func RegisterOffHeapSource(name string, f func() uint64) {
+	offHeapSources.Lock()
+	defer offHeapSources.Unlock()
+	if f == nil {
+		delete(offHeapSources.sources, name)
+		return
+	}
+	offHeapSources.sources[name] = f
+}
+
+// readOffHeapSources reads the registered sources and returns the sum of them
+func (a *adaptiveGCHandler) readOffHeapSources() uint64 {
+	offHeapSources.Lock()
+	sources := make([]OffHeapSource, 0, len(offHeapSources.sources))
+	funcs := make(map[string]func() uint64, len(offHeapSources.sources))
+	for name, f := range offHeapSources.sources {
+		sources = append(sources, OffHeapSource{Name: name})
+		funcs[name] = f
+	}
+	offHeapSources.Unlock()
+
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Name < sources[j].Name
+	})
+	var sum uint64
+	for i := range sources {
+		sources[i].Bytes = funcs[sources[i].Name]()
+		sum += sources[i].Bytes
+	}
+	a.updateStatus(func(s *Status) {
+		s.OffHeap = sources
+	})
+	return sum
+}
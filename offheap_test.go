@@ -0,0 +1,48 @@
+package gogctuner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestOffHeapSources(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1000 << 20)
+	h, _ := newTestHandler(rt, staticConfigurator{config: Config{MaxRAMPercentage: 80}})
+
+	blockCache := uint64(100 << 20)
+	RegisterOffHeapSource("block_cache", func() uint64 { return blockCache })
+	RegisterOffHeapSource("codec", func() uint64 { return 50 << 20 })
+	defer RegisterOffHeapSource("block_cache", nil)
+	defer RegisterOffHeapSource("codec", nil)
+
+	f := func(maxRAMPercentage float64, sources []OffHeapSource) {
+		t.Helper()
+		h.checkAndSetNextGCConfig()
+		s := h.Status()
+		if p := s.AppliedConfig.MaxRAMPercentage; p != maxRAMPercentage {
+			t.Fatalf("unexpected applied MaxRAMPercentage, got: %.2f, want %.2f", p, maxRAMPercentage)
+		}
+		if !reflect.DeepEqual(s.OffHeap, sources) {
+			t.Fatalf("unexpected off-heap sources, got: %+v, want %+v", s.OffHeap, sources)
+		}
+	}
+
+	f(65, []OffHeapSource{{"block_cache", 100 << 20}, {"codec", 50 << 20}})
+	blockCache = 300 << 20
+	f(45, []OffHeapSource{{"block_cache", 300 << 20}, {"codec", 50 << 20}})
+	RegisterOffHeapSource("codec", nil)
+	f(50, []OffHeapSource{{"block_cache", 300 << 20}})
+
+	// the measured non-Go memory includes the off-heap memory
+	h.configurator = staticConfigurator{config: Config{MaxRAMPercentage: 80, AccountNonGoMemory: true}}
+	rt.SetRSS(700 << 20)
+	rt.SetMetric(goMemoryTotalMetric, 300<<20)
+	rt.SetMetric(goMemoryReleasedMetric, 0)
+	f(40, []OffHeapSource{{"block_cache", 300 << 20}})
+	rt.SetRSS(500 << 20)
+	h.status.NonGoMemory = NonGoMemory{} // reset the moving average
+	f(50, []OffHeapSource{{"block_cache", 300 << 20}})
+}
@@ -0,0 +1,155 @@
+package gogctuner
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const defaultOverrideTTL = time.Hour
+
+var overrides = struct {
+	sync.Mutex
+	list []*override
+}{}
+
+type override struct {
+	config  Config
+	ctx     context.Context
+	release func()
+	applied time.Time // the time the override is applied first, guarded by overrides
+}
+
+// Override stacks config on top of the config of the configurator, until ctx is done, release is called,
+// or the TTL set by WithOverrideTTL expires, e.g. to turn off GC for a batch job:
+//
+//	release := gogctuner.Override(ctx, gogctuner.Config{GOGC: -1, MaxRAMPercentage: 90})
+//	defer release()
+//
+// The zero fields of config are not overridden. When several overrides overlap, the most conservative memory wins:
+// the lowest MaxRAMPercentage and the lowest GOGC (a positive GOGC is lower than off), and the other fields are
+// taken from the latest override setting them. An override which makes the config invalid is ignored.
+func Override(ctx context.Context, config Config) (release func()) {
+	o := &override{config: config, ctx: ctx}
+	var (
+		once sync.Once
+		done = make(chan struct{})
+	)
+	release = func() {
+		once.Do(func() {
+			close(done)
+			removeOverride(o)
+			notifyGlobalHandler()
+		})
+	}
+	o.release = release
+
+	overrides.Lock()
+	overrides.list = append(overrides.list, o)
+	overrides.Unlock()
+	notifyGlobalHandler()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		release()
+	}()
+	return release
+}
+
+// WithOverrideTTL sets the maximum duration of an override since the tuner applies it first, an override expires
+// after it even if its context is not done, it's 1 hour by default, see Override.
+func WithOverrideTTL(ttl time.Duration) Option {
+	return func(o *opts) {
+		o.overrideTTL = ttl
+	}
+}
+
+func removeOverride(o *override) {
+	overrides.Lock()
+	defer overrides.Unlock()
+	for i, other := range overrides.list {
+		if other == o {
+			overrides.list = append(overrides.list[:i], overrides.list[i+1:]...)
+			return
+		}
+	}
+}
+
+// notifyGlobalHandler makes the handler enabled by EnableGCTuner re-evaluate the GC parameters
+func notifyGlobalHandler() {
+	if h, _ := globalHandler.Load().(*adaptiveGCHandler); h != nil {
+		h.notify()
+	}
+}
+
+// applyOverrides returns the config with the active overrides applied, and the number of them,
+// the overrides which are done or expired by the clock of the runtime are released
+func (a *adaptiveGCHandler) applyOverrides(config Config) (Config, int) {
+	ttl := a.overrideTTL
+	if ttl <= 0 {
+		ttl = defaultOverrideTTL
+	}
+	now := a.rt.Now()
+	var (
+		configs []Config
+		expired []func()
+	)
+	overrides.Lock()
+	for _, o := range overrides.list {
+		if o.applied.IsZero() {
+			o.applied = now
+		}
+		if o.ctx.Err() != nil || now.Sub(o.applied) >= ttl {
+			expired = append(expired, o.release)
+			continue
+		}
+		configs = append(configs, o.config)
+	}
+	overrides.Unlock()
+	for _, release := range expired {
+		release()
+	}
+	if len(configs) == 0 {
+		return config, 0
+	}
+
+	overridden := mergeOverrides(config, configs)
+	if err := overridden.CheckValid(); err != nil {
+		a.logger.Errorf("gctuner: ignore the overrides, check gc config error: %v", err)
+		return config, 0
+	}
+	return overridden, len(configs)
+}
+
+// mergeOverrides applies the overrides in the order of creation to the base config
+func mergeOverrides(base Config, overrides []Config) Config {
+	var merged Config
+	for _, o := range overrides {
+		gogc, maxRAMPercentage := merged.GOGC, merged.MaxRAMPercentage
+		merged = overrideConfig(merged, o)
+		if gogc != 0 && (merged.GOGC < 0 || (gogc > 0 && gogc < merged.GOGC)) {
+			merged.GOGC = gogc
+		}
+		if maxRAMPercentage != 0 && maxRAMPercentage < merged.MaxRAMPercentage {
+			merged.MaxRAMPercentage = maxRAMPercentage
+		}
+	}
+	return overrideConfig(base, merged)
+}
+
+// overrideConfig returns base with the non-zero fields of o
+func overrideConfig(base, o Config) Config {
+	o.Tiers = nil // the tiers of the overrides are ignored, see Config.Tiers
+	b, v := reflect.ValueOf(&base).Elem(), reflect.ValueOf(o)
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if !reflect.DeepEqual(f.Interface(), reflect.Zero(f.Type()).Interface()) {
+			b.Field(i).Set(f)
+		}
+	}
+	return base
+}
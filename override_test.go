@@ -0,0 +1,102 @@
+package gogctuner
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestMergeOverrides(t *testing.T) {
+	f := func(base Config, overrides []Config, want Config) {
+		t.Helper()
+		if got := mergeOverrides(base, overrides); !reflect.DeepEqual(got, want) {
+			t.Fatalf("unexpected config for %+v with %+v, got: %+v, want %+v", base, overrides, got, want)
+		}
+	}
+	base := Config{MaxRAMPercentage: 70, GOGC: 100}
+	f(base, []Config{{GOGC: -1}}, Config{MaxRAMPercentage: 70, GOGC: -1})
+	f(base, []Config{{MaxRAMPercentage: 90}}, Config{MaxRAMPercentage: 90, GOGC: 100})
+	f(base, []Config{{MaxRAMPercentage: 90, GOGC: -1}, {MaxRAMPercentage: 80, GOGC: 400}}, Config{MaxRAMPercentage: 80, GOGC: 400})
+	f(base, []Config{{MaxRAMPercentage: 80, GOGC: 200}, {MaxRAMPercentage: 95, GOGC: -1}}, Config{MaxRAMPercentage: 80, GOGC: 200})
+	f(base, []Config{{GOGC: 300}, {GOGC: 200}, {IncludeSwap: true}}, Config{MaxRAMPercentage: 70, GOGC: 200, IncludeSwap: true})
+	f(base, []Config{{OOMCooldown: time.Minute}, {OOMCooldown: time.Second}}, Config{MaxRAMPercentage: 70, GOGC: 100, OOMCooldown: time.Second})
+	f(base, []Config{{Tiers: []MemoryTier{{MaxRAMPercentage: 50}}}}, base)
+	tiered := Config{GOGC: 100, Tiers: []MemoryTier{{MaxLimit: 1 << 30, MaxRAMPercentage: 50}, {MaxRAMPercentage: 80}}}
+	f(tiered, []Config{{GOGC: 200}, {Tiers: []MemoryTier{{MaxRAMPercentage: 90}}}},
+		Config{GOGC: 200, Tiers: []MemoryTier{{MaxLimit: 1 << 30, MaxRAMPercentage: 50}, {MaxRAMPercentage: 80}}})
+}
+
+func TestOverride(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1 << 30)
+	h, logger := newTestHandler(rt, staticConfigurator{config: Config{GOGC: 100}})
+
+	f := func(gogc, numOverrides int) {
+		t.Helper()
+		h.checkAndSetNextGCConfig()
+		s := h.Status()
+		if s.AppliedConfig.GOGC != gogc || s.Overrides != numOverrides {
+			t.Fatalf("unexpected status, got: GOGC %d with %d overrides, want GOGC %d with %d overrides",
+				s.AppliedConfig.GOGC, s.Overrides, gogc, numOverrides)
+		}
+		if s.Config.GOGC != 100 {
+			t.Fatalf("unexpected config of the configurator, got: %+v", s.Config)
+		}
+	}
+
+	f(100, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	release1 := Override(ctx, Config{GOGC: -1})
+	f(-1, 1)
+	release2 := Override(context.Background(), Config{GOGC: 200})
+	f(200, 2)
+	release2()
+	release2() // released twice
+	f(-1, 1)
+	cancel()
+	f(100, 0)
+	release1()
+
+	// invalid overrides are ignored
+	release := Override(context.Background(), Config{MaxRAMPercentage: 80, ElevatedWatermark: 95})
+	f(100, 0)
+	release()
+	if len(logger.Errors()) != 1 {
+		t.Fatalf("expecting the invalid override to be logged, got: %q", logger.Errors())
+	}
+}
+
+func TestOverrideTTL(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1 << 30)
+	h := newAdaptiveGCHandler(&opts{
+		configurator: staticConfigurator{config: Config{GOGC: 100}}, logger: &testLogger{}, runtime: rt, overrideTTL: time.Minute,
+	})
+
+	f := func(gogc, numOverrides int) {
+		t.Helper()
+		h.checkAndSetNextGCConfig()
+		s := h.Status()
+		if s.AppliedConfig.GOGC != gogc || s.Overrides != numOverrides {
+			t.Fatalf("unexpected status, got: GOGC %d with %d overrides, want GOGC %d with %d overrides",
+				s.AppliedConfig.GOGC, s.Overrides, gogc, numOverrides)
+		}
+	}
+	release := Override(context.Background(), Config{GOGC: 50})
+	defer release()
+	rt.Advance(time.Hour) // the TTL starts when the override is applied first
+	f(50, 1)
+	rt.Advance(59 * time.Second)
+	f(50, 1)
+	rt.Advance(time.Second)
+	f(100, 0)
+	overrides.Lock()
+	n := len(overrides.list)
+	overrides.Unlock()
+	if n != 0 {
+		t.Fatalf("expecting the expired override to be released, got %d overrides", n)
+	}
+}
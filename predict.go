@@ -0,0 +1,136 @@
+package gogctuner
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// levelSmoothing and trendSmoothing are the smoothing factors of Holt's linear smoothing of the live heap
+	levelSmoothing = 0.5
+	trendSmoothing = 0.3
+	// allocRateSmoothing and errorSmoothing are the weights of the latest samples in the moving averages
+	// of the allocation rate and the GC interval, and the absolute forecast error
+	allocRateSmoothing = 0.3
+	errorSmoothing     = 0.3
+)
+
+// Forecast is the forecast of the live heap in the predictive mode, see Config.Predictive
+type Forecast struct {
+	// LiveHeap is the forecast of the live heap after the next GC cycle
+	LiveHeap uint64
+	// Trend is the smoothed growth of the live heap per GC cycle
+	Trend int64
+	// AllocRate is the smoothed allocation rate in bytes per second
+	AllocRate uint64
+	// GCInterval is the smoothed interval between GC cycles
+	GCInterval time.Duration
+	// Heap is the forecast of the heap at the next GC cycle, i.e. LiveHeap plus AllocRate * GCInterval
+	Heap uint64
+	// LastError is the error of the forecast for the last GC cycle, i.e. the live heap minus the forecast
+	LastError int64
+	// MeanAbsError is the smoothed absolute error of the forecasts
+	MeanAbsError uint64
+	// Samples is the number of the GC cycles observed
+	Samples uint64
+}
+
+// predictor tracks the live heap by Holt's linear smoothing
+type predictor struct {
+	numGC     uint64
+	level     float64
+	trend     float64
+	allocs    uint64
+	allocTime time.Time
+	allocRate float64
+	interval  float64 // the GC interval in seconds
+	lastError float64
+	absError  float64
+	samples   uint64
+}
+
+// observe adds the live heap after a GC cycle
+func (p *predictor) observe(liveHeap float64) {
+	p.samples++
+	switch p.samples {
+	case 1:
+		p.level = liveHeap
+		return
+	case 2:
+		p.trend = liveHeap - p.level
+		p.level = liveHeap
+		return
+	}
+	p.lastError = liveHeap - p.forecast()
+	p.absError = errorSmoothing*math.Abs(p.lastError) + (1-errorSmoothing)*p.absError
+	level := levelSmoothing*liveHeap + (1-levelSmoothing)*(p.level+p.trend)
+	p.trend = trendSmoothing*(level-p.level) + (1-trendSmoothing)*p.trend
+	p.level = level
+}
+
+// observeAllocs adds a sample of the cumulative allocations after a GC cycle
+func (p *predictor) observeAllocs(allocs uint64, now time.Time) {
+	if !p.allocTime.IsZero() && now.After(p.allocTime) && allocs >= p.allocs {
+		interval := now.Sub(p.allocTime).Seconds()
+		rate := float64(allocs-p.allocs) / interval
+		if p.interval == 0 {
+			p.allocRate, p.interval = rate, interval
+		} else {
+			p.allocRate = allocRateSmoothing*rate + (1-allocRateSmoothing)*p.allocRate
+			p.interval = allocRateSmoothing*interval + (1-allocRateSmoothing)*p.interval
+		}
+	}
+	p.allocs, p.allocTime = allocs, now
+}
+
+// forecast returns the forecast of the live heap after the next GC cycle
+func (p *predictor) forecast() float64 {
+	return math.Max(p.level+p.trend, 0)
+}
+
+// forecastHeap returns the forecast of the heap at the next GC cycle, the live heap plus the allocations until then
+func (p *predictor) forecastHeap() float64 {
+	return p.forecast() + p.allocRate*p.interval
+}
+
+// applyForecast lowers MaxRAMPercentage by the forecast growth of the live heap by the next GC cycle,
+// or by the forecast overshoot of the heap at the next GC cycle over the target if it's larger
+func (a *adaptiveGCHandler) applyForecast(config Config) Config {
+	if !config.Predictive {
+		a.predictor = predictor{}
+		a.updateStatus(func(s *Status) {
+			s.Forecast = Forecast{}
+		})
+		return config
+	}
+
+	p := &a.predictor
+	if numGC := a.readNumGC(); numGC != p.numGC || p.samples == 0 {
+		p.numGC = numGC
+		p.observe(float64(a.rt.LiveDatasetSize()))
+		if allocs, err := a.rt.ReadMetric(heapAllocsMetric); err == nil {
+			p.observeAllocs(allocs, a.rt.Now())
+		}
+	}
+	a.updateStatus(func(s *Status) {
+		f := &s.Forecast
+		f.LiveHeap, f.Trend, f.AllocRate = uint64(p.forecast()), int64(p.trend), uint64(p.allocRate)
+		f.GCInterval, f.Heap = time.Duration(p.interval*float64(time.Second)), uint64(p.forecastHeap())
+		f.LastError, f.MeanAbsError, f.Samples = int64(p.lastError), uint64(p.absError), p.samples
+	})
+
+	if config.MaxRAMPercentage <= 0 {
+		return config
+	}
+	limit, err := getMemoryLimit(a.rt, config)
+	if err != nil {
+		return config
+	}
+	target := config.MaxRAMPercentage / 100 * float64(limit)
+	growth := math.Max(p.trend, p.forecastHeap()-target)
+	if growth <= 0 {
+		return config
+	}
+	config.MaxRAMPercentage = math.Max(config.MaxRAMPercentage-100*growth/float64(limit), config.MaxRAMPercentage/2)
+	return config
+}
@@ -0,0 +1,90 @@
+package gogctuner
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestPredictor(t *testing.T) {
+	var p predictor
+	f := func(liveHeap, forecast, lastError float64) {
+		t.Helper()
+		p.observe(liveHeap)
+		if p.forecast() != forecast || p.lastError != lastError {
+			t.Fatalf("unexpected forecast after %.0f, got: %.2f (error %.2f), want %.2f (error %.2f)",
+				liveHeap, p.forecast(), p.lastError, forecast, lastError)
+		}
+	}
+	f(100, 100, 0)
+	f(200, 300, 0)
+	f(300, 400, 0) // linear growth is forecast exactly
+	f(300, 435, -100)
+	f(300, 432.25, -135)
+
+	p.observeAllocs(0, gctunertest.Epoch)
+	p.observeAllocs(100<<20, gctunertest.Epoch.Add(time.Second))
+	p.observeAllocs(300<<20, gctunertest.Epoch.Add(2*time.Second))
+	if want := 0.3*(200<<20) + 0.7*(100<<20); p.allocRate != want {
+		t.Fatalf("unexpected allocation rate, got: %.0f, want %.0f", p.allocRate, want)
+	}
+	p.observeAllocs(400<<20, gctunertest.Epoch.Add(4*time.Second))
+	if math.Abs(p.interval-1.3) > 1e-9 { // 0.3*2s + 0.7*1s
+		t.Fatalf("unexpected GC interval, got: %.2f, want 1.3", p.interval)
+	}
+}
+
+func TestPredictiveMode(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1000 << 20)
+	h, _ := newTestHandler(rt, staticConfigurator{config: Config{MaxRAMPercentage: 80, Predictive: true}})
+	h.installGCHook()
+
+	f := func(liveHeap uint64, maxRAMPercentage float64) {
+		t.Helper()
+		rt.SetLiveHeap(liveHeap)
+		rt.TriggerGC()
+		h.checkAndSetNextGCConfig()
+		if p := h.Status().AppliedConfig.MaxRAMPercentage; math.Abs(p-maxRAMPercentage) > 1e-9 {
+			t.Fatalf("unexpected applied MaxRAMPercentage, got: %.2f, want %.2f", p, maxRAMPercentage)
+		}
+	}
+	f(100<<20, 80)
+	f(200<<20, 70) // growing by 100MB per GC cycle
+	f(300<<20, 70)
+	f(250<<20, 72.25)           // trend 77.5MB
+	f(100<<20, 76.7875)         // trend 32.125MB
+	h.checkAndSetNextGCConfig() // no GC cycle
+	if s := h.Status().Forecast; s.Samples != 5 || s.LastError == 0 || s.MeanAbsError == 0 {
+		t.Fatalf("unexpected forecast, got: %+v", s)
+	}
+}
+
+func TestPredictiveAllocRate(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1000 << 20)
+	rt.SetLiveHeap(500 << 20)
+	h, _ := newTestHandler(rt, staticConfigurator{config: Config{MaxRAMPercentage: 80, Predictive: true}})
+	h.installGCHook()
+
+	// the live heap is flat, only the allocations change the forecast
+	f := func(allocs uint64, maxRAMPercentage float64) {
+		t.Helper()
+		rt.SetMetric(heapAllocsMetric, allocs)
+		rt.Advance(time.Second)
+		rt.TriggerGC()
+		h.checkAndSetNextGCConfig()
+		s := h.Status()
+		if p := s.AppliedConfig.MaxRAMPercentage; math.Abs(p-maxRAMPercentage) > 1e-9 {
+			t.Fatalf("unexpected applied MaxRAMPercentage, got: %.2f (%+v), want %.2f", p, s.Forecast, maxRAMPercentage)
+		}
+	}
+	f(0, 80)
+	f(200<<20, 80)  // the heap at the next GC cycle is 700MB, under the target
+	f(1200<<20, 66) // 500MB + 440MB/s * 1s, 140MB over the target
+	if s := h.Status().Forecast; s.Trend != 0 || s.GCInterval != time.Second || s.Heap != 940<<20 {
+		t.Fatalf("unexpected forecast, got: %+v", s)
+	}
+}
@@ -0,0 +1,97 @@
+package gogctuner
+
+import (
+	"math"
+	"time"
+
+	"github.com/fangwentong/gogctuner/internal/cgroup"
+	"github.com/fangwentong/gogctuner/internal/gcruntime"
+)
+
+const defaultPressureStepPercentage = 10
+
+type (
+	// MemoryPressure is the memory pressure signal of gctuner, see Config.PressureThreshold
+	MemoryPressure struct {
+		// Some is the share of time in which at least some tasks are stalled on memory
+		Some PressureStats
+		// Full is the share of time in which all non-idle tasks are stalled on memory simultaneously
+		Full PressureStats
+		// Error is the error of the latest reading, e.g. the kernel doesn't support PSI
+		Error string
+		// Tightened reports whether the GC is tightened due to the memory pressure
+		Tightened bool
+		// TightenCount is the number of times the GC has been tightened
+		TightenCount uint64
+	}
+
+	// PressureStats are the stall time percentages over 10s, 60s and 300s windows, and the total stall time.
+	PressureStats struct {
+		Avg10  float64
+		Avg60  float64
+		Avg300 float64
+		Total  time.Duration
+	}
+)
+
+// checkMemoryPressure reads the memory pressure and reports whether the GC should be tightened
+func (a *adaptiveGCHandler) checkMemoryPressure(config Config) bool {
+	if config.PressureThreshold <= 0 {
+		a.updateStatus(func(s *Status) {
+			s.MemoryPressure = MemoryPressure{TightenCount: s.MemoryPressure.TightenCount}
+		})
+		return false
+	}
+
+	psi, err := a.rt.ReadMemoryPressure()
+	var tightened bool
+	a.updateStatus(func(s *Status) {
+		p := &s.MemoryPressure
+		if err != nil {
+			// keep the GC as is, the signal is unavailable
+			p.Error = err.Error()
+			tightened = p.Tightened
+			return
+		}
+		p.Some, p.Full, p.Error = toPressureStats(psi.Some), toPressureStats(psi.Full), ""
+		switch {
+		case !p.Tightened && psi.Some.Avg10 > config.PressureThreshold:
+			a.logger.Logf("gctuner: memory pressure %.2f%% exceeds %.2f%%, tighten GC", psi.Some.Avg10, config.PressureThreshold)
+			p.Tightened = true
+			p.TightenCount++
+		case p.Tightened && psi.Some.Avg10 < config.PressureThreshold/2:
+			a.logger.Logf("gctuner: memory pressure %.2f%% subsides, relax GC", psi.Some.Avg10)
+			p.Tightened = false
+		}
+		tightened = p.Tightened
+	})
+	return tightened
+}
+
+// tightenConfig lowers MaxRAMPercentage by PressureStepPercentage (by half at most), and halves GOGC
+func tightenConfig(config Config, rt gcruntime.Runtime) Config {
+	if config.MaxRAMPercentage > 0 {
+		step := config.PressureStepPercentage
+		if step == 0 {
+			step = defaultPressureStepPercentage
+		}
+		config.MaxRAMPercentage = math.Max(config.MaxRAMPercentage-step, config.MaxRAMPercentage/2)
+	}
+	gogc := config.GOGC
+	if gogc == 0 && config.MaxRAMPercentage == 0 {
+		gogc = readGOGC(rt)
+	}
+	if floor := config.gogcFloor(); gogc > floor {
+		config.GOGC = int(math.Max(float64(gogc)/2, float64(floor)))
+	}
+	return config
+}
+
+func toPressureStats(s cgroup.PSIStats) PressureStats {
+	return PressureStats{
+		Avg10:  s.Avg10,
+		Avg60:  s.Avg60,
+		Avg300: s.Avg300,
+		Total:  time.Duration(s.Total) * time.Microsecond,
+	}
+}
@@ -0,0 +1,85 @@
+package gogctuner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestMemoryPressure(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1 << 30)
+	config := Config{MaxRAMPercentage: 80, GOGC: 200, PressureThreshold: 10}
+	h, _ := newTestHandler(rt, staticConfigurator{config: config})
+
+	f := func(avg10 float64, tightened bool, maxRAMPercentage float64, gogc int) {
+		t.Helper()
+		rt.SetMemoryPressure(gctunertest.PSI{Some: gctunertest.PSIStats{Avg10: avg10, Total: 1500}})
+		h.checkAndSetNextGCConfig()
+		s := h.Status()
+		if s.MemoryPressure.Tightened != tightened {
+			t.Fatalf("unexpected tightened with avg10 %.2f, got: %v, want %v", avg10, s.MemoryPressure.Tightened, tightened)
+		}
+		if s.MemoryPressure.Some.Avg10 != avg10 || s.MemoryPressure.Some.Total != 1500*time.Microsecond {
+			t.Fatalf("unexpected pressure reading, got: %+v", s.MemoryPressure.Some)
+		}
+		if s.AppliedConfig.MaxRAMPercentage != maxRAMPercentage || s.AppliedConfig.GOGC != gogc {
+			t.Fatalf("unexpected applied config, got: %+v, want MaxRAMPercentage %.0f, GOGC %d",
+				s.AppliedConfig, maxRAMPercentage, gogc)
+		}
+		if !reflect.DeepEqual(s.Config, config) {
+			t.Fatalf("unexpected config, got: %+v", s.Config)
+		}
+	}
+
+	f(5, false, 80, 200)
+	f(20, true, 70, 100)
+	f(8, true, 70, 100) // hysteresis
+	f(4, false, 80, 200)
+	f(12, true, 70, 100)
+	if n := h.Status().MemoryPressure.TightenCount; n != 2 {
+		t.Fatalf("unexpected tighten count, got: %d, want 2", n)
+	}
+}
+
+func TestMemoryPressureUnavailable(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	h, _ := newTestHandler(rt, staticConfigurator{config: Config{GOGC: 200, PressureThreshold: 10}})
+
+	h.checkAndSetNextGCConfig()
+	s := h.Status()
+	if s.MemoryPressure.Error == "" || s.MemoryPressure.Tightened {
+		t.Fatalf("expecting an error without tightening, got: %+v", s.MemoryPressure)
+	}
+	if rt.GCPercent() != 200 {
+		t.Fatalf("unexpected GOGC, got: %d, want 200", rt.GCPercent())
+	}
+}
+
+func TestTightenConfig(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	f := func(config Config, maxRAMPercentage float64, gogc int) {
+		t.Helper()
+		c := tightenConfig(config, rt)
+		if c.MaxRAMPercentage != maxRAMPercentage || c.GOGC != gogc {
+			t.Fatalf("unexpected config for %+v, got: %+v, want MaxRAMPercentage %.0f, GOGC %d",
+				config, c, maxRAMPercentage, gogc)
+		}
+	}
+
+	f(Config{MaxRAMPercentage: 80}, 70, 0)
+	f(Config{MaxRAMPercentage: 80, PressureStepPercentage: 20}, 60, 0)
+	f(Config{MaxRAMPercentage: 30, PressureStepPercentage: 20}, 15, 0)
+	f(Config{GOGC: 80}, 0, 50)
+	f(Config{GOGC: 40}, 0, 40)
+	f(Config{GOGC: 80, MinGOGC: 10}, 0, 40)
+	f(Config{GOGC: 80, MinGOGC: 70}, 0, 70)
+	f(Config{GOGC: -1}, 0, -1)
+	f(Config{}, 0, 50)
+	rt.Setenv("GOGC", "400")
+	f(Config{}, 0, 200)
+	rt.Setenv("GOGC", "off")
+	f(Config{}, 0, 0)
+}
@@ -0,0 +1,162 @@
+package gogctuner
+
+import (
+	"math"
+	"time"
+)
+
+// Ramp is the progress of the transition to a new MaxRAMPercentage or GOGC, see Config.RampDuration
+type Ramp struct {
+	// Active reports whether a transition is in progress
+	Active bool
+	// FromMaxRAMPercentage and ToMaxRAMPercentage are the MaxRAMPercentage the transition starts from and moves to
+	FromMaxRAMPercentage float64
+	ToMaxRAMPercentage   float64
+	// FromGOGC and ToGOGC are the GOGC the transition starts from and moves to
+	FromGOGC int
+	ToGOGC   int
+	// MaxRAMPercentage and GOGC are the current values of the transition
+	MaxRAMPercentage float64
+	GOGC             int
+	// Progress is the progress of the transition, range [0, 1]
+	Progress float64
+	// Started is the time the transition started
+	Started time.Time
+	// Aborts is the number of the transitions completed at once because the memory usage
+	// approached the memory limit, LastAbort is the time of the last one
+	Aborts    uint64
+	LastAbort time.Time
+}
+
+// ramp is the state of the transition, it's accessed by checkAndSetNextGCConfig only
+type ramp struct {
+	initialized bool
+	target      Config
+	numGC       uint64    // the number of GC cycles at the last update
+	updated     time.Time // the time of the last update
+	status      Ramp
+}
+
+// applyRamp moves MaxRAMPercentage and GOGC of the config toward their new values gradually,
+// so that e.g. lowering the memory limit below the current heap doesn't cause a GC storm
+func (a *adaptiveGCHandler) applyRamp(config Config) Config {
+	r := &a.ramp
+	if config.RampDuration <= 0 && config.RampCycles <= 0 {
+		*r = ramp{initialized: true, target: config}
+		a.updateStatus(func(s *Status) {
+			s.Ramp = Ramp{Aborts: s.Ramp.Aborts, LastAbort: s.Ramp.LastAbort}
+		})
+		return config
+	}
+	numGC := a.readNumGC()
+	if !r.initialized { // the initial config is applied at once
+		*r = ramp{initialized: true, target: config, numGC: numGC}
+		return config
+	}
+
+	st := &r.status
+	if config.MaxRAMPercentage != r.target.MaxRAMPercentage || config.GOGC != r.target.GOGC {
+		from := r.target
+		if st.Active {
+			from.MaxRAMPercentage, from.GOGC = st.MaxRAMPercentage, st.GOGC
+		}
+		st.Active, st.Progress, st.Started = true, 0, a.rt.Now()
+		st.FromMaxRAMPercentage, st.ToMaxRAMPercentage = from.MaxRAMPercentage, config.MaxRAMPercentage
+		st.FromGOGC, st.ToGOGC = from.GOGC, config.GOGC
+		r.numGC, r.updated = numGC, st.Started
+	}
+	r.target = config
+	if !st.Active {
+		return config
+	}
+
+	now := a.rt.Now()
+	var step float64
+	if config.RampCycles > 0 {
+		step = float64(numGC-r.numGC) / float64(config.RampCycles)
+	}
+	if config.RampDuration > 0 {
+		step = math.Max(step, float64(now.Sub(r.updated))/float64(config.RampDuration))
+	}
+	r.numGC, r.updated = numGC, now
+	progress := st.Progress + step
+	if a.nearMemoryLimit(config) {
+		if rampPercentageOrder(st.ToMaxRAMPercentage) < rampPercentageOrder(st.FromMaxRAMPercentage) ||
+			a.rampGOGCOrder(st.ToGOGC, st.ToMaxRAMPercentage) < a.rampGOGCOrder(st.FromGOGC, st.FromMaxRAMPercentage) {
+			// toward safety: complete the tightening at once
+			progress = 1
+			st.Aborts++
+			st.LastAbort = now
+		} else {
+			// hold the loosening
+			progress = st.Progress
+		}
+	}
+	st.Progress = math.Min(progress, 1)
+	st.MaxRAMPercentage = rampPercentage(st.FromMaxRAMPercentage, st.ToMaxRAMPercentage, st.Progress)
+	st.GOGC = rampGOGC(st.FromGOGC, st.ToGOGC, st.Progress)
+	st.Active = st.Progress < 1
+
+	status := *st
+	a.updateStatus(func(s *Status) {
+		s.Ramp = status
+	})
+	config.MaxRAMPercentage, config.GOGC = st.MaxRAMPercentage, st.GOGC
+	return config
+}
+
+// nearMemoryLimit reports whether the memory usage of the process exceeds
+// EmergencyRAMPercentage of the memory limit
+func (a *adaptiveGCHandler) nearMemoryLimit(config Config) bool {
+	limit := a.rt.ReadMemoryLimit()
+	if limit == 0 {
+		return false
+	}
+	return float64(a.readUsage()) >= config.emergencyPercentage()/100*float64(limit)
+}
+
+// rampPercentage interpolates the MaxRAMPercentage, the transition between unset and set is not gradual
+func rampPercentage(from, to, progress float64) float64 {
+	if from <= 0 || to <= 0 {
+		return rampSwitch(from, to, progress)
+	}
+	return from + (to-from)*progress
+}
+
+// rampGOGC interpolates the GOGC, only positive values are interpolated
+func rampGOGC(from, to int, progress float64) int {
+	if from <= 0 || to <= 0 {
+		return int(rampSwitch(float64(from), float64(to), progress))
+	}
+	return int(math.Round(float64(from) + float64(to-from)*progress))
+}
+
+// rampPercentageOrder orders the MaxRAMPercentage by the memory it allows, unset (0) is the loosest
+func rampPercentageOrder(maxRAMPercentage float64) float64 {
+	if maxRAMPercentage <= 0 {
+		return math.Inf(1)
+	}
+	return maxRAMPercentage
+}
+
+// rampGOGCOrder orders the GOGC by the memory it allows, GOGC off (-1) is the loosest. Unset GOGC (0) is off
+// with MaxRAMPercentage set, and the GOGC environment variable otherwise
+func (a *adaptiveGCHandler) rampGOGCOrder(gogc int, maxRAMPercentage float64) float64 {
+	if gogc == 0 {
+		if maxRAMPercentage > 0 {
+			return math.Inf(1)
+		}
+		gogc = readGOGC(a.rt)
+	}
+	if gogc < 0 {
+		return math.Inf(1)
+	}
+	return float64(gogc)
+}
+
+func rampSwitch(from, to, progress float64) float64 {
+	if progress < 1 {
+		return from
+	}
+	return to
+}
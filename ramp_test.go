@@ -0,0 +1,183 @@
+package gogctuner
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestRampDuration(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1000 << 20)
+	configurator := &silentConfigurator{config: Config{MaxRAMPercentage: 90, RampDuration: time.Minute}}
+	h, _ := newTestHandler(rt, configurator)
+
+	f := func(advance time.Duration, maxRAMPercentage float64, active bool) {
+		t.Helper()
+		rt.Advance(advance)
+		h.checkAndSetNextGCConfig()
+		s := h.Status()
+		if math.Abs(s.AppliedConfig.MaxRAMPercentage-maxRAMPercentage) > 1e-9 || s.Ramp.Active != active {
+			t.Fatalf("unexpected applied MaxRAMPercentage, got: %.2f (ramp %+v), want %.2f (active %v)",
+				s.AppliedConfig.MaxRAMPercentage, s.Ramp, maxRAMPercentage, active)
+		}
+	}
+	f(0, 90, false) // the initial config is applied at once
+	configurator.set(Config{MaxRAMPercentage: 60, RampDuration: time.Minute})
+	f(0, 90, true)
+	f(30*time.Second, 75, true)
+	f(15*time.Second, 67.5, true)
+	configurator.set(Config{MaxRAMPercentage: 80, RampDuration: time.Minute}) // restart from the current value
+	f(0, 67.5, true)
+	f(30*time.Second, 73.75, true)
+	f(time.Minute, 80, false)
+	f(time.Minute, 80, false)
+
+	configurator.set(Config{MaxRAMPercentage: 60})
+	f(0, 60, false)
+}
+
+func TestRampCycles(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1000 << 20)
+	rt.SetMetric(gcCyclesMetric, 10)
+	configurator := &silentConfigurator{config: Config{MaxRAMPercentage: 80, GOGC: 200, RampCycles: 4}}
+	h, _ := newTestHandler(rt, configurator)
+
+	f := func(numGC uint64, gogc int, progress float64) {
+		t.Helper()
+		rt.SetMetric(gcCyclesMetric, numGC)
+		h.checkAndSetNextGCConfig()
+		s := h.Status()
+		if s.AppliedConfig.GOGC != gogc || s.Ramp.Progress != progress {
+			t.Fatalf("unexpected applied GOGC, got: %d (progress %.2f), want %d (progress %.2f)",
+				s.AppliedConfig.GOGC, s.Ramp.Progress, gogc, progress)
+		}
+	}
+	f(10, 200, 0)
+	configurator.set(Config{MaxRAMPercentage: 80, GOGC: 100, RampCycles: 4})
+	f(10, 200, 0)
+	f(11, 175, 0.25)
+	f(11, 175, 0.25) // no GC cycle
+	f(13, 125, 0.75)
+	f(20, 100, 1)
+
+	// the transition between set and unset is not gradual
+	configurator.set(Config{MaxRAMPercentage: 80, RampCycles: 4})
+	f(21, 100, 0)
+	f(24, 100, 0.75)
+	f(25, 0, 1)
+}
+
+func TestRampNearMemoryLimit(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1000 << 20)
+	configurator := &silentConfigurator{config: Config{MaxRAMPercentage: 90, RampDuration: time.Minute}}
+	h, _ := newTestHandler(rt, configurator)
+	h.checkAndSetNextGCConfig()
+
+	f := func(maxRAMPercentage float64, aborts uint64) {
+		t.Helper()
+		rt.Advance(15 * time.Second)
+		h.checkAndSetNextGCConfig()
+		s := h.Status()
+		if s.AppliedConfig.MaxRAMPercentage != maxRAMPercentage || s.Ramp.Aborts != aborts {
+			t.Fatalf("unexpected applied MaxRAMPercentage, got: %.2f (ramp %+v), want %.2f (aborts %d)",
+				s.AppliedConfig.MaxRAMPercentage, s.Ramp, maxRAMPercentage, aborts)
+		}
+	}
+	configurator.set(Config{MaxRAMPercentage: 50, RampDuration: time.Minute})
+	h.checkAndSetNextGCConfig()
+	f(80, 0)
+	rt.SetRSS(960 << 20) // above 95% of the memory limit
+	f(50, 1)             // tightening is completed at once
+
+	configurator.set(Config{MaxRAMPercentage: 90, RampDuration: time.Minute})
+	h.checkAndSetNextGCConfig()
+	f(50, 1) // loosening is held
+	rt.SetRSS(500 << 20)
+	f(60, 1)
+
+	// unsetting MaxRAMPercentage removes the soft memory limit, which is a loosening
+	configurator.set(Config{MaxRAMPercentage: 80, GOGC: 100, RampDuration: time.Minute})
+	h.checkAndSetNextGCConfig()
+	f(65, 1)
+	f(70, 1)
+	f(75, 1)
+	f(80, 1)
+	rt.SetRSS(960 << 20)
+	configurator.set(Config{GOGC: 100, RampDuration: time.Minute})
+	h.checkAndSetNextGCConfig()
+	f(80, 1)
+	f(80, 1)
+	rt.SetRSS(500 << 20)
+	f(80, 1)
+	f(80, 1)
+	f(80, 1)
+	f(0, 1)
+}
+
+func TestRampGOGCOff(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1000 << 20)
+	rt.SetRSS(960 << 20) // above 95% of the memory limit
+	configurator := &silentConfigurator{config: Config{MaxRAMPercentage: 80, GOGC: 100, RampDuration: time.Minute}}
+	h, _ := newTestHandler(rt, configurator)
+	h.checkAndSetNextGCConfig()
+
+	f := func(gogc int, aborts uint64) {
+		t.Helper()
+		rt.Advance(15 * time.Second)
+		h.checkAndSetNextGCConfig()
+		s := h.Status()
+		if s.AppliedConfig.GOGC != gogc || s.Ramp.Aborts != aborts {
+			t.Fatalf("unexpected applied GOGC, got: %d (ramp %+v), want %d (aborts %d)", s.AppliedConfig.GOGC, s.Ramp, gogc, aborts)
+		}
+	}
+	// turning GOGC off is a loosening, which is held near the memory limit
+	configurator.set(Config{MaxRAMPercentage: 80, GOGC: -1, RampDuration: time.Minute})
+	f(100, 0)
+	rt.SetRSS(500 << 20)
+	f(100, 0)
+	f(100, 0)
+	f(100, 0)
+	f(-1, 0)
+
+	// turning GOGC on is a tightening, which is completed at once near the memory limit
+	configurator.set(Config{MaxRAMPercentage: 80, GOGC: 100, RampDuration: time.Minute})
+	f(-1, 0)
+	rt.SetRSS(960 << 20)
+	f(100, 1)
+
+	// unset GOGC is off with MaxRAMPercentage set, which is a loosening
+	rt.SetRSS(500 << 20)
+	configurator.set(Config{MaxRAMPercentage: 80, GOGC: 200, RampDuration: time.Minute})
+	f(100, 1)
+	f(125, 1)
+	f(150, 1)
+	f(175, 1)
+	f(200, 1)
+	rt.SetRSS(960 << 20)
+	configurator.set(Config{MaxRAMPercentage: 80, RampDuration: time.Minute})
+	f(200, 1)
+	f(200, 1)
+
+	// unset GOGC is the GOGC environment variable without MaxRAMPercentage
+	rt.SetRSS(500 << 20)
+	configurator.set(Config{GOGC: 200, RampDuration: time.Minute})
+	f(200, 1)
+	f(200, 1)
+	f(200, 1)
+	f(200, 1)
+	rt.SetRSS(960 << 20)
+	rt.Setenv("GOGC", "100")
+	configurator.set(Config{RampDuration: time.Minute})
+	f(0, 2) // tightening
+	rt.Setenv("GOGC", "300")
+	configurator.set(Config{GOGC: 200, RampDuration: time.Minute})
+	f(200, 3) // tightening
+	configurator.set(Config{RampDuration: time.Minute})
+	f(200, 3) // loosening is held
+}
@@ -0,0 +1,188 @@
+package gogctuner
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const requestCheckInterval = time.Second
+
+var errNoMemoryRequest = errors.New("memory request is not set")
+
+// MemoryRequestProvider returns the memory request of the container in bytes, see WithMemoryRequest
+type MemoryRequestProvider func() (uint64, error)
+
+// MemoryRequest is the state of the memory request of the container, see WithMemoryRequest
+type MemoryRequest struct {
+	// Request is the latest memory request in bytes, 0 if unknown
+	Request uint64
+	// Error is the error of the latest reading of the memory request
+	Error string
+	// Usage is the latest memory usage of the process, i.e. the RSS
+	Usage uint64
+	// Target is the memory targeted by Config.RequestPercentage, Expanded reports whether it's expanded
+	// above the request for the live heap
+	Target   uint64
+	Expanded bool
+	// AboveRequest is the total time the usage has been above the request, which makes the pod an eviction
+	// candidate under node memory pressure, AboveRequestSince is the start of the current period above the request,
+	// the time between two checks is accounted to the usage seen by the later one
+	AboveRequest      time.Duration
+	AboveRequestSince time.Time
+}
+
+// WithMemoryRequest sets the provider of the memory request of the container, e.g. a Kubernetes Burstable pod,
+// the request is read every second, see Config.RequestPercentage and Status.MemoryRequest.
+func WithMemoryRequest(provider MemoryRequestProvider) Option {
+	return func(o *opts) {
+		o.requestProvider = provider
+	}
+}
+
+// MemoryRequestFromEnv reads the memory request from the environment variable, e.g. set by the Kubernetes downward API:
+//
+//	env:
+//	- name: MEMORY_REQUEST
+//	  valueFrom:
+//	    resourceFieldRef:
+//	      resource: requests.memory
+func MemoryRequestFromEnv(name string) MemoryRequestProvider {
+	return func() (uint64, error) {
+		v := os.Getenv(name)
+		if v == "" {
+			return 0, errNoMemoryRequest
+		}
+		return parseQuantity(v)
+	}
+}
+
+// MemoryRequestFromFile reads the memory request from the file, e.g. a Kubernetes downward API volume,
+// which follows the in-place resizes of the pod.
+func MemoryRequestFromFile(path string) MemoryRequestProvider {
+	return func() (uint64, error) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+		return parseQuantity(string(data))
+	}
+}
+
+// parseQuantity parses a Kubernetes memory quantity in bytes, e.g. 536870912, 512Mi, 1.5Gi or 1G,
+// the fractional bytes are rounded up, the exponent notation (e.g. 1e9) isn't supported
+func parseQuantity(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	number, multiplier := s, uint64(1)
+	for _, u := range []struct {
+		suffix     string
+		multiplier uint64
+	}{
+		{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40}, {"Pi", 1 << 50}, {"Ei", 1 << 60},
+		{"k", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12}, {"P", 1e15}, {"E", 1e18},
+	} {
+		if strings.HasSuffix(number, u.suffix) {
+			number, multiplier = strings.TrimSuffix(number, u.suffix), u.multiplier
+			break
+		}
+	}
+	whole, fraction := number, "0"
+	if i := strings.IndexByte(number, '.'); i >= 0 {
+		whole, fraction = number[:i], number[i+1:]
+	}
+	n, err := strconv.ParseUint(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory quantity %q: %v", s, err)
+	}
+	if len(fraction) > 18 { // the digits beyond are below a byte
+		fraction = fraction[:18]
+	}
+	f, err := strconv.ParseUint(fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory quantity %q: %v", s, err)
+	}
+	fractionBytes := uint64(math.Ceil(float64(f) / math.Pow10(len(fraction)) * float64(multiplier)))
+	if n > math.MaxUint64/multiplier || n*multiplier > math.MaxUint64-fractionBytes {
+		return 0, fmt.Errorf("memory quantity %q overflows", s)
+	}
+	return n*multiplier + fractionBytes, nil
+}
+
+// watchMemoryRequest reads the memory request, and accounts the time spent above it periodically
+func (a *adaptiveGCHandler) watchMemoryRequest() {
+	if a.requestProvider == nil {
+		return
+	}
+	t := a.rt.NewTicker(requestCheckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C():
+		case <-a.done:
+			return
+		}
+		a.checkMemoryRequest()
+	}
+}
+
+func (a *adaptiveGCHandler) checkMemoryRequest() {
+	request, err := a.requestProvider()
+	usage := a.readUsage()
+	now, last := a.rt.Now(), a.requestChecked
+	if last.IsZero() {
+		last = now
+	}
+	a.requestChecked = now
+	a.updateStatus(func(s *Status) {
+		r := &s.MemoryRequest
+		if err != nil {
+			r.Error = err.Error()
+		} else {
+			r.Request, r.Error = request, ""
+		}
+		r.Usage = usage
+		if r.Request == 0 || usage <= r.Request {
+			r.AboveRequestSince = time.Time{}
+			return
+		}
+		// the time since the last check is accounted to the usage seen now
+		if r.AboveRequestSince.IsZero() {
+			r.AboveRequestSince = last
+		}
+		r.AboveRequest += now.Sub(last)
+	})
+}
+
+// applyMemoryRequest targets RequestPercentage of the memory request, and expands the target toward
+// MaxRAMPercentage of the memory limit only if the live heap needs it
+func (a *adaptiveGCHandler) applyMemoryRequest(config Config) Config {
+	if config.RequestPercentage <= 0 || config.MaxRAMPercentage <= 0 || a.requestProvider == nil {
+		return config
+	}
+	a.statusMu.Lock()
+	request := a.status.MemoryRequest.Request
+	a.statusMu.Unlock()
+	limit, err := getMemoryLimit(a.rt, config)
+	if err != nil || request == 0 {
+		return config
+	}
+
+	ceiling := config.MaxRAMPercentage / 100 * float64(limit)
+	target := math.Min(config.RequestPercentage/100*float64(request), ceiling)
+	// the live heap needs the room for MinGOGC at least
+	need := float64(a.rt.LiveDatasetSize()) * (1 + float64(config.gogcFloor())/100)
+	expanded := need > target && target < ceiling
+	if expanded {
+		target = math.Min(need, ceiling)
+	}
+	a.updateStatus(func(s *Status) {
+		s.MemoryRequest.Target, s.MemoryRequest.Expanded = uint64(target), expanded
+	})
+	config.MaxRAMPercentage = 100 * target / float64(limit)
+	return config
+}
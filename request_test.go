@@ -0,0 +1,143 @@
+package gogctuner
+
+import (
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestParseQuantity(t *testing.T) {
+	f := func(s string, want uint64, expectValid bool) {
+		t.Helper()
+		got, err := parseQuantity(s)
+		if (err == nil) != expectValid || got != want {
+			t.Fatalf("unexpected quantity for %q, got: %d (error %v), want %d (valid %v)", s, got, err, want, expectValid)
+		}
+	}
+	f("536870912", 512<<20, true)
+	f("512Mi\n", 512<<20, true)
+	f("2Gi", 2<<30, true)
+	f("1G", 1e9, true)
+	f("100k", 1e5, true)
+	f("", 0, false)
+	f("1.5Gi", 3<<29, true)
+	f("0.5", 1, true)
+	f("2.25M", 2250000, true)
+	f("8Ei", 8<<60, true)
+	f("1P", 1e15, true)
+	f("1.Gi", 0, false)
+	f(".5Gi", 0, false)
+	f("1.-5Gi", 0, false)
+	f("1e9", 0, false)
+	f("-1", 0, false)
+	f("99999999999Ti", 0, false)
+	f("16Ei", 0, false)
+	f("15.99999999999999999Ei", 0, false)
+}
+
+func TestMemoryRequestProviders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gctuner")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "memory_request")
+	if err = ioutil.WriteFile(path, []byte("256Mi\n"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if request, err := MemoryRequestFromFile(path)(); err != nil || request != 256<<20 {
+		t.Fatalf("unexpected memory request, got: %d (error %v), want %d", request, err, 256<<20)
+	}
+	if _, err = MemoryRequestFromFile(filepath.Join(dir, "missing"))(); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+
+	const name = "GCTUNER_TEST_MEMORY_REQUEST"
+	defer os.Unsetenv(name)
+	if _, err = MemoryRequestFromEnv(name)(); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	os.Setenv(name, "1073741824")
+	if request, err := MemoryRequestFromEnv(name)(); err != nil || request != 1<<30 {
+		t.Fatalf("unexpected memory request, got: %d (error %v), want %d", request, err, 1<<30)
+	}
+}
+
+func TestMemoryRequestTarget(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(4 << 30)
+	var request uint64 = 1 << 30
+	h, _ := newTestHandler(rt, staticConfigurator{config: Config{MaxRAMPercentage: 90, RequestPercentage: 100}})
+	h.requestProvider = func() (uint64, error) {
+		if request == 0 {
+			return 0, errNoMemoryRequest
+		}
+		return request, nil
+	}
+
+	f := func(liveHeap uint64, maxRAMPercentage float64, expanded bool) {
+		t.Helper()
+		rt.SetLiveHeap(liveHeap)
+		h.checkMemoryRequest()
+		h.checkAndSetNextGCConfig()
+		s := h.Status()
+		if math.Abs(s.AppliedConfig.MaxRAMPercentage-maxRAMPercentage) > 1e-9 || s.MemoryRequest.Expanded != expanded {
+			t.Fatalf("unexpected applied MaxRAMPercentage for the live heap %d, got: %.4f (%+v), want %.4f (expanded %v)",
+				liveHeap, s.AppliedConfig.MaxRAMPercentage, s.MemoryRequest, maxRAMPercentage, expanded)
+		}
+	}
+	f(200<<20, 25, false)             // the request
+	f(800<<20, 1200.0/4096*100, true) // the live heap with MinGOGC
+	f(3<<30, 90, true)                // the ceiling of the limit
+	f(200<<20, 25, false)             // back to the request
+
+	// the latest request is kept on errors
+	request = 0
+	f(200<<20, 25, false)
+	if s := h.Status().MemoryRequest; s.Request != 1<<30 || s.Error == "" {
+		t.Fatalf("unexpected memory request status, got: %+v", s)
+	}
+}
+
+func TestAboveMemoryRequest(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	h, _ := newTestHandler(rt, staticConfigurator{})
+	h.requestProvider = func() (uint64, error) {
+		return 1 << 30, nil
+	}
+
+	f := func(usage uint64, above time.Duration, since time.Time) {
+		t.Helper()
+		rt.SetRSS(usage)
+		h.checkMemoryRequest()
+		rt.Advance(requestCheckInterval)
+		if s := h.Status().MemoryRequest; s.AboveRequest != above || !s.AboveRequestSince.Equal(since) {
+			t.Fatalf("unexpected time above the request, got: %v since %v, want %v since %v",
+				s.AboveRequest, s.AboveRequestSince, above, since)
+		}
+	}
+	f(512<<20, 0, time.Time{})
+	// the time since the last check is accounted to the usage seen now
+	start := rt.Now().Add(-requestCheckInterval)
+	f(1536<<20, time.Second, start)
+	f(1536<<20, 2*time.Second, start)
+	f(1536<<20, 3*time.Second, start)
+	f(512<<20, 3*time.Second, time.Time{})
+
+	// the check is delayed
+	rt.Advance(4 * requestCheckInterval)
+	start = rt.Now().Add(-5 * requestCheckInterval)
+	f(1536<<20, 8*time.Second, start)
+
+	// the usage is compared with the latest known request
+	h.requestProvider = func() (uint64, error) {
+		return 0, errors.New("boom")
+	}
+	f(1536<<20, 9*time.Second, start)
+}
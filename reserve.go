@@ -0,0 +1,120 @@
+package gogctuner
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	errNotEnabled = errors.New("gctuner: not enabled")
+	// ErrNoHeadroom is returned by Reserve if the reservation cannot fit in the effective limit
+	ErrNoHeadroom = errors.New("gctuner: not enough headroom for the reservation")
+)
+
+// Reservation is the memory reserved by Reserve, it must be released by Release
+type Reservation struct {
+	h     *adaptiveGCHandler
+	bytes uint64
+	once  *sync.Once
+}
+
+type reserveOpts struct {
+	gc bool
+}
+
+// ReserveOption is the option of Reserve
+type ReserveOption func(*reserveOpts)
+
+// ReserveWithGC forces a GC to make room for the reservation if the headroom is not enough.
+func ReserveWithGC() ReserveOption {
+	return func(o *reserveOpts) {
+		o.gc = true
+	}
+}
+
+// Reserve tells the tuner about a known large allocation before it's made, e.g. loading a model or building an index.
+// It fails with ErrNoHeadroom if bytes doesn't fit in the headroom, i.e. the effective limit minus the live heap,
+// the off-heap memory and the other reservations. The reserved bytes are subtracted from the budget of the Go runtime
+// like the off-heap memory, the reservation should be released once the allocation is done or freed.
+func Reserve(bytes uint64, options ...ReserveOption) (Reservation, error) {
+	h, _ := globalHandler.Load().(*adaptiveGCHandler)
+	if h == nil {
+		return Reservation{}, errNotEnabled
+	}
+	return h.reserve(bytes, options...)
+}
+
+// Bytes returns the reserved bytes
+func (r Reservation) Bytes() uint64 {
+	return r.bytes
+}
+
+// Release releases the reservation, it's safe to call Release more than once
+func (r Reservation) Release() {
+	if r.once == nil {
+		return
+	}
+	r.once.Do(func() {
+		r.h.reserveMu.Lock()
+		r.h.reserved -= r.bytes
+		r.h.reserveMu.Unlock()
+		r.h.notify()
+	})
+}
+
+func (a *adaptiveGCHandler) reserve(bytes uint64, options ...ReserveOption) (Reservation, error) {
+	o := &reserveOpts{}
+	for _, opt := range options {
+		opt(o)
+	}
+
+	a.reserveMu.Lock()
+	defer a.reserveMu.Unlock()
+	headroom, err := a.headroom()
+	if err == nil && headroom < bytes && o.gc {
+		a.rt.GC()
+		headroom, err = a.headroom()
+	}
+	if err != nil {
+		return Reservation{}, err
+	}
+	if headroom < bytes {
+		a.logger.Errorf("gctuner: cannot reserve %s, headroom %s", printMemorySize(bytes), printMemorySize(headroom))
+		return Reservation{}, ErrNoHeadroom
+	}
+	a.reserved += bytes
+	a.logger.Logf("gctuner: reserved %s, headroom %s", printMemorySize(bytes), printMemorySize(headroom-bytes))
+	a.notify()
+	return Reservation{h: a, bytes: bytes, once: &sync.Once{}}, nil
+}
+
+// headroom returns the effective limit minus the live heap, the off-heap memory and the reservations,
+// it must be called with reserveMu held
+func (a *adaptiveGCHandler) headroom() (uint64, error) {
+	a.statusMu.Lock()
+	info := a.status.HeapPressure
+	a.statusMu.Unlock()
+	if info.Limit == 0 {
+		return 0, fmt.Errorf("gctuner: the effective limit is unknown")
+	}
+	used := a.rt.LiveDatasetSize() + info.OffHeap + a.reserved
+	if used >= info.Limit {
+		return 0, nil
+	}
+	return info.Limit - used, nil
+}
+
+func (a *adaptiveGCHandler) reservedBytes() uint64 {
+	a.reserveMu.Lock()
+	defer a.reserveMu.Unlock()
+	return a.reserved
+}
+
+// notify makes the handler re-evaluate the GC parameters
+func (a *adaptiveGCHandler) notify() {
+	select {
+	case a.ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,59 @@
+package gogctuner
+
+import (
+	"testing"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestReserve(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1000 << 20)
+	rt.SetLiveHeap(300 << 20)
+	h, _ := newTestHandler(rt, staticConfigurator{config: Config{MaxRAMPercentage: 80}})
+	h.checkAndSetNextGCConfig()
+
+	r1, err := h.reserve(400 << 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err = h.reserve(200 << 20); err != ErrNoHeadroom {
+		t.Fatalf("unexpected error, got: %v, want %v", err, ErrNoHeadroom)
+	}
+
+	h.checkAndSetNextGCConfig()
+	s := h.Status()
+	if s.AppliedConfig.MaxRAMPercentage != 40 || s.HeapPressure.Reserved != 400<<20 {
+		t.Fatalf("expecting the reservation to be accounted, got: %+v, %+v", s.AppliedConfig, s.HeapPressure)
+	}
+
+	// a forced GC makes room
+	rt.NotifyGC(func() bool {
+		rt.SetLiveHeap(100 << 20)
+		return false
+	})
+	r2, err := h.reserve(200<<20, ReserveWithGC())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if rt.NumGC() != 1 {
+		t.Fatalf("unexpected number of GC, got: %d, want 1", rt.NumGC())
+	}
+
+	r1.Release()
+	r1.Release() // released twice
+	r2.Release()
+	h.checkAndSetNextGCConfig()
+	if s = h.Status(); s.AppliedConfig.MaxRAMPercentage != 80 || s.HeapPressure.Reserved != 0 {
+		t.Fatalf("expecting the reservations to be released, got: %+v, %+v", s.AppliedConfig, s.HeapPressure)
+	}
+	Reservation{}.Release()
+}
+
+func TestReserveUnknownLimit(t *testing.T) {
+	h, _ := newTestHandler(gctunertest.NewRuntime(), staticConfigurator{})
+	h.checkAndSetNextGCConfig()
+	if _, err := h.reserve(1); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
@@ -0,0 +1,233 @@
+package gogctuner
+
+import (
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/fangwentong/gogctuner/internal/gcruntime"
+)
+
+const (
+	gcCPUMetric    = "/cpu/classes/gc/total:cpu-seconds"
+	totalCPUMetric = "/cpu/classes/total:cpu-seconds"
+	gcPausesMetric = "/gc/pauses:seconds"
+
+	defaultRollbackGCCPUPercentage = 50
+	defaultRollbackPausePercentage = 50
+	// the regressions below them are ignored as noise
+	minRollbackGCCPUFraction = 0.01
+	minRollbackPause         = time.Millisecond
+)
+
+// EventRollback is emitted when a new config is reverted because the GC overhead regressed,
+// see Config.RollbackWindow and Event.Rollback
+const EventRollback EventType = "rollback"
+
+// GCOverhead is the GC overhead measured over a window
+type GCOverhead struct {
+	// CPUFraction is the fraction of the CPU time spent in GC, 0 if unavailable, it requires go1.20
+	CPUFraction float64
+	// PauseP50 and PauseP99 are the percentiles of the GC pauses
+	PauseP50 time.Duration
+	PauseP99 time.Duration
+	// Pauses is the number of the GC pauses
+	Pauses uint64
+	// Window is the duration of the measurement
+	Window time.Duration
+}
+
+// RollbackReport is the detail of a rollback
+type RollbackReport struct {
+	// Config is the reverted config, GoodConfig is the config reverted to
+	Config     Config
+	GoodConfig Config
+	// Baseline is the GC overhead with GoodConfig, Observed is the GC overhead with Config
+	Baseline GCOverhead
+	Observed GCOverhead
+}
+
+// Rollback is the state of the regression guard of new configs, see Config.RollbackWindow
+type Rollback struct {
+	// Evaluating reports whether a new config is being evaluated, since EvaluationStarted
+	Evaluating        bool
+	EvaluationStarted time.Time
+	// Baseline is the GC overhead with the last good config
+	Baseline GCOverhead
+	// Rollbacks is the number of the rollbacks, LastRollback is the time of the last one
+	Rollbacks    uint64
+	LastRollback time.Time
+	// Rejected reports whether the config of the configurator is rejected by the last rollback,
+	// it's ignored until it changes
+	Rejected bool
+}
+
+// guard is the state of the regression guard, it's accessed by checkAndSetNextGCConfig only
+type guard struct {
+	initialized bool
+	good        Config // the last good config
+	candidate   Config // the config being evaluated
+	rejected    *Config
+	hasBaseline bool
+	snapshot    gcSnapshot // the start of the current window
+	status      Rollback
+}
+
+// gcSnapshot is a reading of the cumulative GC metrics
+type gcSnapshot struct {
+	time     time.Time
+	gcCPU    float64
+	totalCPU float64
+	pauses   gcruntime.Histogram
+}
+
+// guardConfig evaluates the GC overhead of a new config over Config.RollbackWindow against the last good config,
+// and returns the last good config instead if the new one regresses
+func (a *adaptiveGCHandler) guardConfig(config Config) Config {
+	g := &a.guard
+	if config.RollbackWindow <= 0 {
+		*g = guard{status: Rollback{Rollbacks: g.status.Rollbacks, LastRollback: g.status.LastRollback}}
+		a.updateStatus(func(s *Status) {
+			s.Rollback = g.status
+		})
+		return config
+	}
+	defer func() {
+		status := g.status
+		a.updateStatus(func(s *Status) {
+			s.Rollback = status
+		})
+	}()
+
+	now := a.rt.Now()
+	if g.rejected != nil && reflect.DeepEqual(config, *g.rejected) {
+		return g.good
+	}
+	g.rejected, g.status.Rejected = nil, false
+	if !g.initialized { // the initial config is good
+		g.initialized, g.good, g.snapshot = true, config, a.readGCSnapshot(now)
+		return config
+	}
+
+	st := &g.status
+	if !st.Evaluating {
+		if reflect.DeepEqual(config, g.good) {
+			if now.Sub(g.snapshot.time) >= config.RollbackWindow {
+				cur := a.readGCSnapshot(now)
+				st.Baseline, g.hasBaseline, g.snapshot = gcOverhead(g.snapshot, cur), true, cur
+			}
+			return config
+		}
+		cur := a.readGCSnapshot(now)
+		if !g.hasBaseline { // the partial window is the best we have
+			st.Baseline, g.hasBaseline = gcOverhead(g.snapshot, cur), true
+		}
+		st.Evaluating, st.EvaluationStarted, g.candidate, g.snapshot = true, now, config, cur
+		return config
+	}
+
+	if !reflect.DeepEqual(config, g.candidate) {
+		if reflect.DeepEqual(config, g.good) {
+			st.Evaluating = false
+		} else { // evaluate the latest config from now
+			st.EvaluationStarted, g.candidate, g.snapshot = now, config, a.readGCSnapshot(now)
+		}
+		return config
+	}
+	if now.Sub(st.EvaluationStarted) < config.RollbackWindow {
+		return config
+	}
+
+	cur := a.readGCSnapshot(now)
+	observed := gcOverhead(g.snapshot, cur)
+	st.Evaluating, g.snapshot = false, cur
+	if !regressed(config, st.Baseline, observed) {
+		g.good, st.Baseline = config, observed
+		return config
+	}
+
+	rejected := config
+	g.rejected, st.Rejected = &rejected, true
+	st.Rollbacks++
+	st.LastRollback = now
+	a.logger.Errorf("gctuner: GC overhead regressed with the new config, rolling back, baseline %+v, observed %+v",
+		st.Baseline, observed)
+	a.emit(Event{Type: EventRollback, Time: now, Delta: 1, Total: st.Rollbacks, Rollback: &RollbackReport{
+		Config: config, GoodConfig: g.good, Baseline: st.Baseline, Observed: observed,
+	}})
+	return g.good
+}
+
+// regressed reports whether the observed GC overhead exceeds the thresholds of the config over the baseline
+func regressed(config Config, baseline, observed GCOverhead) bool {
+	cpuPercentage := config.RollbackGCCPUPercentage
+	if cpuPercentage == 0 {
+		cpuPercentage = defaultRollbackGCCPUPercentage
+	}
+	pausePercentage := config.RollbackPausePercentage
+	if pausePercentage == 0 {
+		pausePercentage = defaultRollbackPausePercentage
+	}
+	if baseline.CPUFraction > 0 && observed.CPUFraction >= minRollbackGCCPUFraction &&
+		observed.CPUFraction > baseline.CPUFraction*(1+cpuPercentage/100) {
+		return true
+	}
+	return baseline.Pauses > 0 && observed.PauseP99 >= minRollbackPause &&
+		float64(observed.PauseP99) > float64(baseline.PauseP99)*(1+pausePercentage/100)
+}
+
+// readGCSnapshot reads the GC metrics, the unavailable ones are zero
+func (a *adaptiveGCHandler) readGCSnapshot(now time.Time) gcSnapshot {
+	s := gcSnapshot{time: now}
+	s.gcCPU, _ = a.rt.ReadFloat64Metric(gcCPUMetric)
+	s.totalCPU, _ = a.rt.ReadFloat64Metric(totalCPUMetric)
+	s.pauses, _ = a.rt.ReadHistogramMetric(gcPausesMetric)
+	return s
+}
+
+// gcOverhead returns the GC overhead between two snapshots
+func gcOverhead(from, to gcSnapshot) GCOverhead {
+	o := GCOverhead{Window: to.time.Sub(from.time)}
+	if cpu := to.totalCPU - from.totalCPU; cpu > 0 {
+		o.CPUFraction = (to.gcCPU - from.gcCPU) / cpu
+	}
+	if len(to.pauses.Counts) == 0 || len(from.pauses.Counts) != len(to.pauses.Counts) {
+		return o
+	}
+	counts := make([]uint64, len(to.pauses.Counts))
+	for i := range counts {
+		if to.pauses.Counts[i] >= from.pauses.Counts[i] {
+			counts[i] = to.pauses.Counts[i] - from.pauses.Counts[i]
+		}
+		o.Pauses += counts[i]
+	}
+	o.PauseP50 = histogramPercentile(counts, to.pauses.Buckets, 0.5)
+	o.PauseP99 = histogramPercentile(counts, to.pauses.Buckets, 0.99)
+	return o
+}
+
+// histogramPercentile returns the upper boundary of the bucket of the percentile q of a histogram in seconds,
+// or the lower boundary for the last unbounded bucket
+func histogramPercentile(counts []uint64, buckets []float64, q float64) time.Duration {
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+	if total == 0 || len(buckets) != len(counts)+1 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(total)))
+	var cum uint64
+	for i, c := range counts {
+		cum += c
+		if cum < rank {
+			continue
+		}
+		v := buckets[i+1]
+		if math.IsInf(v, 1) {
+			v = buckets[i]
+		}
+		return time.Duration(math.Max(v, 0) * float64(time.Second))
+	}
+	return 0
+}
@@ -0,0 +1,107 @@
+package gogctuner
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestHistogramPercentile(t *testing.T) {
+	buckets := []float64{math.Inf(-1), 0.001, 0.01, math.Inf(1)}
+	f := func(counts []uint64, q float64, want time.Duration) {
+		t.Helper()
+		if got := histogramPercentile(counts, buckets, q); got != want {
+			t.Fatalf("unexpected percentile %.2f of %v, got: %v, want %v", q, counts, got, want)
+		}
+	}
+	f([]uint64{0, 0, 0}, 0.5, 0)
+	f([]uint64{10, 0, 0}, 0.99, time.Millisecond)
+	f([]uint64{50, 50, 0}, 0.5, time.Millisecond)
+	f([]uint64{50, 50, 0}, 0.99, 10*time.Millisecond)
+	f([]uint64{98, 1, 1}, 0.99, 10*time.Millisecond)
+	f([]uint64{98, 0, 2}, 0.99, 10*time.Millisecond) // the lower boundary of the unbounded bucket
+	f([]uint64{1, 1}, 0.5, 0)                        // mismatched buckets
+}
+
+func TestRollback(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(1000 << 20)
+	buckets := []float64{0, 0.0001, 0.001, 0.01, 0.1, math.Inf(1)}
+	var gcCPU, totalCPU float64
+	pauses := make([]uint64, len(buckets)-1)
+	run := func(d time.Duration, gcFraction float64, newPauses ...uint64) {
+		gcCPU += gcFraction * d.Seconds()
+		totalCPU += d.Seconds()
+		for i, n := range newPauses {
+			pauses[i] += n
+		}
+		rt.SetFloat64Metric(gcCPUMetric, gcCPU)
+		rt.SetFloat64Metric(totalCPUMetric, totalCPU)
+		rt.SetHistogramMetric(gcPausesMetric, gctunertest.Histogram{Counts: pauses, Buckets: buckets})
+		rt.Advance(d)
+	}
+	run(0, 0)
+
+	config := func(maxRAMPercentage float64) Config {
+		return Config{MaxRAMPercentage: maxRAMPercentage, RollbackWindow: time.Minute}
+	}
+	configurator := &silentConfigurator{config: config(80)}
+	h, _ := newTestHandler(rt, configurator)
+	var events []Event
+	h.eventHandler = func(e Event) {
+		events = append(events, e)
+	}
+	f := func(maxRAMPercentage float64, evaluating bool, rollbacks uint64) {
+		t.Helper()
+		h.checkAndSetNextGCConfig()
+		s := h.Status()
+		if s.AppliedConfig.MaxRAMPercentage != maxRAMPercentage || s.Rollback.Evaluating != evaluating ||
+			s.Rollback.Rollbacks != rollbacks || len(events) != int(rollbacks) {
+			t.Fatalf("unexpected applied MaxRAMPercentage, got: %.0f (%+v, %d events), want %.0f (evaluating %v, rollbacks %d)",
+				s.AppliedConfig.MaxRAMPercentage, s.Rollback, len(events), maxRAMPercentage, evaluating, rollbacks)
+		}
+	}
+	f(80, false, 0)
+	run(time.Minute, 0.02, 0, 100)
+	f(80, false, 0) // the baseline
+
+	// the GC CPU regresses
+	configurator.set(config(60))
+	f(60, true, 0)
+	run(30*time.Second, 0.08, 0, 50)
+	f(60, true, 0)
+	run(30*time.Second, 0.08, 0, 50)
+	f(80, false, 1)
+	e := events[0]
+	if e.Type != EventRollback || e.Rollback == nil || e.Rollback.Config.MaxRAMPercentage != 60 ||
+		e.Rollback.GoodConfig.MaxRAMPercentage != 80 || math.Abs(e.Rollback.Baseline.CPUFraction-0.02) > 1e-9 ||
+		math.Abs(e.Rollback.Observed.CPUFraction-0.08) > 1e-9 || e.Rollback.Observed.Pauses != 100 {
+		t.Fatalf("unexpected rollback event, got: %+v (%+v)", e, e.Rollback)
+	}
+	if !h.Status().Rollback.Rejected {
+		t.Fatalf("expecting the config to be rejected")
+	}
+	run(time.Minute, 0.02, 0, 100)
+	f(80, false, 1) // the rejected config is ignored until it changes
+
+	// no regression
+	configurator.set(config(70))
+	f(70, true, 1)
+	run(time.Minute, 0.025, 0, 100)
+	f(70, false, 1)
+
+	// the GC pauses regress
+	configurator.set(config(50))
+	f(50, true, 1)
+	run(time.Minute, 0.02, 0, 50, 50)
+	f(70, false, 2)
+	if p := events[1].Rollback.Observed.PauseP99; p != 10*time.Millisecond {
+		t.Fatalf("unexpected p99 pause, got: %v, want 10ms", p)
+	}
+
+	// disabled
+	configurator.set(Config{MaxRAMPercentage: 50})
+	f(50, false, 2)
+}
@@ -0,0 +1,212 @@
+package sim
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/fangwentong/gogctuner"
+)
+
+// GCCycle is a GC cycle parsed from a GODEBUG=gctrace=1 line, e.g.
+//
+//	gc 42 @12.345s 2%: 0.018+1.2+0.003 ms clock, 0.14+0.35/2.1/4.9+0.029 ms cpu, 120->130->60 MB, 140 MB goal, 0 MB stacks, 0 MB globals, 8 P
+//
+// The stacks and globals fields are only printed since go1.18, and the line ends with "(forced)"
+// if the cycle was triggered by runtime.GC or the periodic forced GC.
+// Heap sizes in the trace are in MB, they are converted to bytes.
+type GCCycle struct {
+	Num int
+	// Time is the time since the program start.
+	Time time.Duration
+	// CPUFraction is the fraction of the CPU time used by GC since the program start.
+	CPUFraction float64
+	Forced      bool
+
+	// Wall clock times of the GC phases.
+	SweepTermination time.Duration // stop-the-world
+	ConcurrentMark   time.Duration
+	MarkTermination  time.Duration // stop-the-world
+
+	// CPU times of the GC phases.
+	SweepTerminationCPU time.Duration
+	AssistCPU           time.Duration
+	BackgroundCPU       time.Duration
+	IdleCPU             time.Duration
+	MarkTerminationCPU  time.Duration
+
+	HeapStart uint64 // heap size at GC start
+	HeapEnd   uint64 // heap size at GC end
+	LiveHeap  uint64 // marked heap
+	HeapGoal  uint64
+	Stacks    uint64 // scannable stack size, go1.18+
+	Globals   uint64 // scannable globals size, go1.18+
+	Procs     int
+}
+
+var gctraceRe = regexp.MustCompile(`gc (\d+) @([\d.]+)s (\d+)%: ` +
+	`([\d.]+)\+([\d.]+)\+([\d.]+) ms clock, ` +
+	`([\d.]+)\+([\d.]+)/([\d.]+)/([\d.]+)\+([\d.]+) ms cpu, ` +
+	`(\d+)->(\d+)->(\d+) MB, (\d+) MB goal, ` +
+	`(?:(\d+) MB stacks, (\d+) MB globals, )?` +
+	`(\d+) P( \(forced\))?`)
+
+// Pause returns the total stop-the-world time of the cycle.
+func (c GCCycle) Pause() time.Duration {
+	return c.SweepTermination + c.MarkTermination
+}
+
+// CPU returns the total CPU time of the cycle.
+func (c GCCycle) CPU() time.Duration {
+	return c.SweepTerminationCPU + c.AssistCPU + c.BackgroundCPU + c.IdleCPU + c.MarkTerminationCPU
+}
+
+// ParseGCTrace reads the gctrace lines from r, other lines (including the ones of the scavenger) are skipped.
+// The gctrace line may be prefixed, e.g. by a log timestamp.
+func ParseGCTrace(r io.Reader) ([]GCCycle, error) {
+	var cycles []GCCycle
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		m := gctraceRe.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		c, err := parseGCCycle(m)
+		if err != nil {
+			return nil, fmt.Errorf("sim: invalid gctrace line %q: %v", m[0], err)
+		}
+		cycles = append(cycles, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cycles, nil
+}
+
+func parseGCCycle(m []string) (GCCycle, error) {
+	p := &fieldParser{fields: m[1:]}
+	c := GCCycle{
+		Num:                 p.int(),
+		Time:                p.duration(time.Second),
+		CPUFraction:         float64(p.int()) / 100,
+		SweepTermination:    p.duration(time.Millisecond),
+		ConcurrentMark:      p.duration(time.Millisecond),
+		MarkTermination:     p.duration(time.Millisecond),
+		SweepTerminationCPU: p.duration(time.Millisecond),
+		AssistCPU:           p.duration(time.Millisecond),
+		BackgroundCPU:       p.duration(time.Millisecond),
+		IdleCPU:             p.duration(time.Millisecond),
+		MarkTerminationCPU:  p.duration(time.Millisecond),
+		HeapStart:           p.mb(),
+		HeapEnd:             p.mb(),
+		LiveHeap:            p.mb(),
+		HeapGoal:            p.mb(),
+		Stacks:              p.mb(),
+		Globals:             p.mb(),
+		Procs:               p.int(),
+		Forced:              p.next() != "",
+	}
+	return c, p.err
+}
+
+// fieldParser parses the submatches in order, and keeps the first error.
+type fieldParser struct {
+	fields []string
+	err    error
+}
+
+func (p *fieldParser) next() string {
+	s := p.fields[0]
+	p.fields = p.fields[1:]
+	return s
+}
+
+func (p *fieldParser) int() int {
+	n, err := strconv.Atoi(p.next())
+	p.setErr(err)
+	return n
+}
+
+func (p *fieldParser) duration(unit time.Duration) time.Duration {
+	f, err := strconv.ParseFloat(p.next(), 64)
+	p.setErr(err)
+	return time.Duration(f * float64(unit))
+}
+
+// mb parses an optional size in MB
+func (p *fieldParser) mb() uint64 {
+	s := p.next()
+	if s == "" {
+		return 0
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	p.setErr(err)
+	return n << 20
+}
+
+func (p *fieldParser) setErr(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// TraceFromGCTrace converts GC cycles into a heap time series with a fixed memory limit.
+// The live heap of a sample is the marked heap plus the scannable stacks, and the allocation rate is
+// derived from the bytes allocated until the next cycle starts.
+func TraceFromGCTrace(cycles []GCCycle, memoryLimit uint64) []Sample {
+	samples := make([]Sample, 0, len(cycles))
+	for i, c := range cycles {
+		s := Sample{Time: c.Time, LiveHeap: c.LiveHeap + c.Stacks, MemoryLimit: memoryLimit}
+		if i+1 < len(cycles) {
+			next := cycles[i+1]
+			allocated := float64(next.HeapStart) - float64(c.LiveHeap) + float64(c.HeapEnd) - float64(c.HeapStart)
+			if elapsed := (next.Time - c.Time).Seconds(); elapsed > 0 && allocated > 0 {
+				s.AllocRate = allocated / elapsed
+			}
+		} else if i > 0 {
+			s.AllocRate = samples[i-1].AllocRate
+		}
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+type (
+	// WhatIfCycle is an observed GC cycle with the GC parameters the tuner would have chosen after it.
+	WhatIfCycle struct {
+		GCCycle
+		Decision gogctuner.Decision
+	}
+
+	// WhatIfReport reports how the tuner would have behaved on a recorded gctrace.
+	WhatIfReport struct {
+		Cycles          []WhatIfCycle
+		ObservedGCCount int
+		// Result is the simulation of the recorded workload with the tuner enabled.
+		Result Result
+	}
+)
+
+// WhatIf evaluates the config against the recorded GC cycles of a process running with the given memory limit.
+func WhatIf(cycles []GCCycle, memoryLimit uint64, opts Options) (WhatIfReport, error) {
+	opts = withDefaults(opts)
+	trace := TraceFromGCTrace(cycles, memoryLimit)
+	res, err := Run(trace, opts)
+	if err != nil {
+		return WhatIfReport{}, err
+	}
+	report := WhatIfReport{
+		Cycles:          make([]WhatIfCycle, 0, len(cycles)),
+		ObservedGCCount: len(cycles),
+		Result:          res,
+	}
+	var prev gogctuner.Decision
+	for i, c := range cycles {
+		prev = opts.Strategy.Decide(opts.Config.ForMemoryLimit(trace[i].MemoryLimit), input(trace[i], prev.GOGC))
+		report.Cycles = append(report.Cycles, WhatIfCycle{GCCycle: c, Decision: prev})
+	}
+	return report, nil
+}
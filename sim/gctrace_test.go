@@ -0,0 +1,89 @@
+package sim
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fangwentong/gogctuner"
+)
+
+const testGCTrace = `2024/05/01 10:00:00 starting
+gc 1 @0.011s 1%: 0.009+0.32+0.003 ms clock, 0.037+0.14/0.26/0.58+0.013 ms cpu, 4->4->0 MB, 5 MB goal, 4 P
+scvg: inuse: 4, idle: 59, sys: 63, released: 0, consumed: 63 (MB)
+2024/05/01 10:00:01 gc 2 @1.000s 2%: 0.018+1.2+0.003 ms clock, 0.14+0.35/2.1/4.9+0.029 ms cpu, 120->130->60 MB, 140 MB goal, 1 MB stacks, 0 MB globals, 8 P
+gc 3 @2.000s 2%: 0.020+1.5+0.004 ms clock, 0.16+0.40/2.3/5.0+0.030 ms cpu, 130->140->60 MB, 120 MB goal, 1 MB stacks, 0 MB globals, 8 P (forced)
+`
+
+func TestParseGCTrace(t *testing.T) {
+	cycles, err := ParseGCTrace(strings.NewReader(testGCTrace))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(cycles) != 3 {
+		t.Fatalf("unexpected cycles, got: %d, want 3", len(cycles))
+	}
+	// pre go1.18 format
+	c := cycles[0]
+	if c.Num != 1 || c.Time != 11*time.Millisecond || c.CPUFraction != 0.01 || c.Procs != 4 || c.Forced {
+		t.Fatalf("unexpected cycle: %+v", c)
+	}
+	if c.HeapStart != 4*MB || c.HeapEnd != 4*MB || c.LiveHeap != 0 || c.HeapGoal != 5*MB || c.Stacks != 0 {
+		t.Fatalf("unexpected heap sizes: %+v", c)
+	}
+	if c.Pause() != 12*time.Microsecond {
+		t.Fatalf("unexpected pause, got: %v, want 12µs", c.Pause())
+	}
+	if c.AssistCPU != 140*time.Microsecond || c.BackgroundCPU != 260*time.Microsecond || c.IdleCPU != 580*time.Microsecond {
+		t.Fatalf("unexpected cpu times: %+v", c)
+	}
+	// go1.18+ format with a log prefix
+	c = cycles[1]
+	if c.Num != 2 || c.HeapStart != 120*MB || c.HeapEnd != 130*MB || c.LiveHeap != 60*MB ||
+		c.HeapGoal != 140*MB || c.Stacks != MB || c.Globals != 0 || c.Procs != 8 || c.Forced {
+		t.Fatalf("unexpected cycle: %+v", c)
+	}
+	if !cycles[2].Forced {
+		t.Fatalf("expecting a forced cycle: %+v", cycles[2])
+	}
+}
+
+func TestTraceFromGCTrace(t *testing.T) {
+	cycles, err := ParseGCTrace(strings.NewReader(testGCTrace))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	trace := TraceFromGCTrace(cycles[1:], GB)
+	// 60MB live after cycle 2, 130MB at the start of cycle 3, plus 10MB allocated during the mark phase
+	if trace[0].AllocRate != 80*MB || trace[1].AllocRate != 80*MB {
+		t.Fatalf("unexpected alloc rates: %+v", trace)
+	}
+	if trace[0].LiveHeap != 61*MB || trace[0].MemoryLimit != GB || trace[1].Time != 2*time.Second {
+		t.Fatalf("unexpected samples: %+v", trace)
+	}
+}
+
+func TestWhatIf(t *testing.T) {
+	cycles, err := ParseGCTrace(strings.NewReader(testGCTrace))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	report, err := WhatIf(cycles[1:], GB, Options{
+		Config:   gogctuner.Config{MaxRAMPercentage: 50},
+		Strategy: gogctuner.MemoryLimitStrategy,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if report.ObservedGCCount != 2 || len(report.Cycles) != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	want := gogctuner.Decision{GOGC: -1, MemoryLimit: GB / 2}
+	if report.Cycles[0].Decision != want {
+		t.Fatalf("unexpected decision, got: %+v, want %+v", report.Cycles[0].Decision, want)
+	}
+	// 80MB/s from 61MB live never reaches the 512MB soft limit within a second
+	if report.Result.GCCount != 0 {
+		t.Fatalf("unexpected GC count, got: %d, want 0", report.Result.GCCount)
+	}
+}
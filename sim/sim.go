@@ -0,0 +1,181 @@
+// Package sim replays heap time series against gctuner strategies offline.
+//
+// The simulator models the runtime pacer with a single heap counter: the heap grows by the allocation rate,
+// a GC cycle is triggered once the heap reaches the goal derived from GOGC and the soft memory limit,
+// and the heap drops back to the live heap after each cycle. Like the real tuner, the strategy is
+// re-evaluated after every GC cycle.
+package sim
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/fangwentong/gogctuner"
+)
+
+const (
+	defaultStep        = 10 * time.Millisecond
+	defaultMarkRate    = 1 << 30 // 1GB of live heap marked per CPU second
+	defaultGCFixedCost = 100 * time.Microsecond
+	minHeapSize        = 4 << 20 // 4MB, same as the runtime heapminimum with GOGC=100
+)
+
+var errTraceTooShort = errors.New("sim: the trace must contain at least 2 samples")
+
+type (
+	// Sample is one point of a heap time series, it's effective until the next sample.
+	Sample struct {
+		// Time is the offset of the sample from the start of the series.
+		Time time.Duration
+		// LiveHeap is the live heap size in bytes.
+		LiveHeap uint64
+		// AllocRate is the allocation rate in bytes per second.
+		AllocRate float64
+		// MemoryLimit is the memory limit (e.g. the cgroup limit) in bytes, exceeding it is an OOM.
+		MemoryLimit uint64
+	}
+
+	// Options configures a simulation run.
+	Options struct {
+		// Config is the gctuner config to evaluate.
+		Config gogctuner.Config
+		// Strategy is the decision function of the tuner, gogctuner.DefaultStrategy() if nil.
+		Strategy gogctuner.Strategy
+		// Step is the simulation time step, 10ms if not specified.
+		Step time.Duration
+		// MarkRate is the number of live heap bytes marked per CPU second, 1GB/s if not specified.
+		MarkRate float64
+		// GCFixedCost is the fixed CPU cost of a GC cycle, 100µs if not specified.
+		GCFixedCost time.Duration
+		// CPUs is the number of CPUs available to the process, used to compute GCCPUFraction, 1 if not specified.
+		CPUs int
+	}
+
+	// Cycle describes a simulated GC cycle.
+	Cycle struct {
+		Time     time.Duration
+		Heap     uint64 // heap size when the cycle was triggered
+		LiveHeap uint64
+		Goal     uint64
+		Decision gogctuner.Decision // GC parameters chosen by the tuner after the cycle
+	}
+
+	// OOMEvent describes a moment the heap exceeded the memory limit.
+	OOMEvent struct {
+		Time        time.Duration
+		Heap        uint64
+		MemoryLimit uint64
+	}
+
+	// Result is the outcome of a simulation run.
+	Result struct {
+		Duration      time.Duration
+		GCCount       int
+		PeakHeap      uint64
+		GCCPU         time.Duration // estimated CPU time spent in GC
+		GCCPUFraction float64       // GCCPU / (Duration * CPUs)
+		OOMs          []OOMEvent
+		Cycles        []Cycle
+	}
+)
+
+// Run replays the trace against the strategy in opts and reports how the GC would behave.
+// When the heap exceeds the memory limit, an OOMEvent is recorded and the heap is reset to the live heap,
+// as if the process was restarted with its working set.
+func Run(trace []Sample, opts Options) (Result, error) {
+	if len(trace) < 2 {
+		return Result{}, errTraceTooShort
+	}
+	if err := opts.Config.CheckValid(); err != nil {
+		return Result{}, err
+	}
+	opts = withDefaults(opts)
+
+	var (
+		res      Result
+		start    = trace[0].Time
+		end      = trace[len(trace)-1].Time
+		idx      = 0
+		heap     = float64(trace[0].LiveHeap)
+		decision = opts.Strategy.Decide(opts.Config.ForMemoryLimit(trace[0].MemoryLimit), input(trace[0], 0))
+		goal     = heapGoal(trace[0].LiveHeap, decision)
+		gcCPU    float64
+	)
+	for t := start; t <= end; t += opts.Step {
+		for idx+1 < len(trace) && trace[idx+1].Time <= t {
+			idx++
+		}
+		s := trace[idx]
+		heap += s.AllocRate * opts.Step.Seconds()
+		heap = math.Max(heap, float64(s.LiveHeap))
+		if uint64(heap) > res.PeakHeap {
+			res.PeakHeap = uint64(heap)
+		}
+
+		if s.MemoryLimit > 0 && uint64(heap) > s.MemoryLimit {
+			res.OOMs = append(res.OOMs, OOMEvent{Time: t - start, Heap: uint64(heap), MemoryLimit: s.MemoryLimit})
+			heap = float64(s.LiveHeap)
+			continue
+		}
+		if heap < float64(goal) {
+			continue
+		}
+
+		// GC cycle
+		res.GCCount++
+		gcCPU += opts.GCFixedCost.Seconds() + float64(s.LiveHeap)/opts.MarkRate
+		cycle := Cycle{Time: t - start, Heap: uint64(heap), LiveHeap: s.LiveHeap, Goal: goal}
+		heap = float64(s.LiveHeap)
+		decision = opts.Strategy.Decide(opts.Config.ForMemoryLimit(s.MemoryLimit), input(s, decision.GOGC))
+		goal = heapGoal(s.LiveHeap, decision)
+		cycle.Decision = decision
+		res.Cycles = append(res.Cycles, cycle)
+	}
+
+	res.Duration = end - start
+	res.GCCPU = time.Duration(gcCPU * float64(time.Second))
+	if res.Duration > 0 {
+		res.GCCPUFraction = gcCPU / (res.Duration.Seconds() * float64(opts.CPUs))
+	}
+	return res, nil
+}
+
+func withDefaults(opts Options) Options {
+	if opts.Strategy == nil {
+		opts.Strategy = gogctuner.DefaultStrategy()
+	}
+	if opts.Step <= 0 {
+		opts.Step = defaultStep
+	}
+	if opts.MarkRate <= 0 {
+		opts.MarkRate = defaultMarkRate
+	}
+	if opts.GCFixedCost <= 0 {
+		opts.GCFixedCost = defaultGCFixedCost
+	}
+	if opts.CPUs <= 0 {
+		opts.CPUs = 1
+	}
+	return opts
+}
+
+// input returns the strategy input of the sample, prevGOGC is the GOGC of the previous decision, 0 if none.
+func input(s Sample, prevGOGC int) gogctuner.StrategyInput {
+	return gogctuner.StrategyInput{MemoryLimit: s.MemoryLimit, LiveHeap: s.LiveHeap, PreviousGOGC: prevGOGC}
+}
+
+// heapGoal returns the heap size that triggers the next GC cycle,
+// which is the lower one of the GOGC based goal and the soft memory limit.
+func heapGoal(live uint64, d gogctuner.Decision) uint64 {
+	goal := uint64(math.MaxUint64)
+	if d.GOGC >= 0 {
+		gogcGoal := float64(live) * (1 + float64(d.GOGC)/100)
+		gogcGoal = math.Max(gogcGoal, minHeapSize*float64(d.GOGC)/100)
+		goal = uint64(gogcGoal)
+	}
+	if d.MemoryLimit >= 0 && uint64(d.MemoryLimit) < goal {
+		goal = uint64(d.MemoryLimit)
+	}
+	return goal
+}
@@ -0,0 +1,122 @@
+package sim
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fangwentong/gogctuner"
+)
+
+const (
+	MB = 1 << 20
+	GB = 1 << 30
+)
+
+func constantTrace(d time.Duration, live uint64, allocRate float64, limit uint64) []Sample {
+	return []Sample{
+		{Time: 0, LiveHeap: live, AllocRate: allocRate, MemoryLimit: limit},
+		{Time: d, LiveHeap: live, AllocRate: allocRate, MemoryLimit: limit},
+	}
+}
+
+func TestRun(t *testing.T) {
+	f := func(trace []Sample, opts Options, wantGCCount, wantOOMs int) {
+		t.Helper()
+		res, err := Run(trace, opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if res.GCCount != wantGCCount {
+			t.Fatalf("unexpected GC count, got: %d, want %d", res.GCCount, wantGCCount)
+		}
+		if len(res.OOMs) != wantOOMs {
+			t.Fatalf("unexpected OOM count, got: %d, want %d", len(res.OOMs), wantOOMs)
+		}
+	}
+	trace := constantTrace(10*time.Second, 100*MB, 100*MB, GB)
+	// GOGC=100, a GC cycle per second
+	f(trace, Options{Config: gogctuner.Config{GOGC: 100}, Strategy: gogctuner.GOGCStrategy}, 10, 0)
+	// GOGC=300, a GC cycle every 3 seconds
+	f(trace, Options{Config: gogctuner.Config{GOGC: 300}, Strategy: gogctuner.GOGCStrategy}, 3, 0)
+	// soft limit 800MB with GOGC off, a GC cycle every 7 seconds
+	f(trace, Options{Config: gogctuner.Config{MaxRAMPercentage: 80}, Strategy: gogctuner.MemoryLimitStrategy}, 1, 0)
+	// GOGC=100 with 600MB live heap exceeds the 1GB limit
+	f(constantTrace(500*time.Millisecond, 600*MB, GB, GB),
+		Options{Config: gogctuner.Config{GOGC: 100}, Strategy: gogctuner.GOGCStrategy}, 0, 1)
+	// the tuner lowers GOGC to keep the heap under the limit
+	f(constantTrace(500*time.Millisecond, 600*MB, GB, GB),
+		Options{Config: gogctuner.Config{MaxRAMPercentage: 80}, Strategy: gogctuner.GOGCStrategy}, 1, 0)
+}
+
+func TestRunStats(t *testing.T) {
+	res, err := Run(constantTrace(10*time.Second, 100*MB, 100*MB, GB), Options{
+		Config:   gogctuner.Config{GOGC: 100},
+		Strategy: gogctuner.GOGCStrategy,
+		MarkRate: 100 * MB,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.Duration != 10*time.Second {
+		t.Fatalf("unexpected duration, got: %v, want %v", res.Duration, 10*time.Second)
+	}
+	if res.PeakHeap < 199*MB || res.PeakHeap > 201*MB {
+		t.Fatalf("unexpected peak heap, got: %d, want 200MB", res.PeakHeap)
+	}
+	// 10 cycles, each marks 100MB at 100MB/s
+	if res.GCCPU < 10*time.Second || res.GCCPU > 10*time.Second+10*time.Millisecond {
+		t.Fatalf("unexpected GC CPU, got: %v, want 10s", res.GCCPU)
+	}
+	if len(res.Cycles) != res.GCCount {
+		t.Fatalf("unexpected cycles, got: %d, want %d", len(res.Cycles), res.GCCount)
+	}
+}
+
+func TestRunInvalid(t *testing.T) {
+	if _, err := Run(constantTrace(time.Second, MB, MB, GB)[:1], Options{}); err == nil {
+		t.Fatalf("expecting non-nil error for a short trace")
+	}
+	if _, err := Run(constantTrace(time.Second, MB, MB, GB), Options{Config: gogctuner.Config{MaxRAMPercentage: 101}}); err == nil {
+		t.Fatalf("expecting non-nil error for an invalid config")
+	}
+}
+
+func TestReadTrace(t *testing.T) {
+	data := `time,live_heap,alloc_rate,memory_limit
+# warm up
+0,1048576,1024,1073741824
+1.5,2097152,2048,1073741824
+1m,4194304,0,2147483648
+`
+	samples, err := ReadTrace(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []Sample{
+		{Time: 0, LiveHeap: MB, AllocRate: 1024, MemoryLimit: GB},
+		{Time: 1500 * time.Millisecond, LiveHeap: 2 * MB, AllocRate: 2048, MemoryLimit: GB},
+		{Time: time.Minute, LiveHeap: 4 * MB, AllocRate: 0, MemoryLimit: 2 * GB},
+	}
+	if len(samples) != len(want) {
+		t.Fatalf("unexpected samples, got: %+v, want %+v", samples, want)
+	}
+	for i := range want {
+		if samples[i] != want[i] {
+			t.Fatalf("unexpected sample #%d, got: %+v, want %+v", i, samples[i], want[i])
+		}
+	}
+}
+
+func TestReadTraceFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		if _, err := ReadTrace(strings.NewReader(data)); err == nil {
+			t.Fatalf("expecting non-nil error for %q", data)
+		}
+	}
+	f("0,1,2\n")
+	f("0,1,2,3\nx,1,2,3\n")
+	f("2,1,2,3\n1,1,2,3\n")
+	f("0,1,2,-3\n")
+}
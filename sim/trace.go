@@ -0,0 +1,79 @@
+package sim
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReadTrace reads a heap time series in CSV format, one sample per record:
+//
+//	time,live_heap,alloc_rate,memory_limit
+//
+// time is either a Go duration ("1m30s") or a number of seconds, live_heap and memory_limit are in bytes,
+// alloc_rate is in bytes per second. A header record and lines starting with '#' are skipped.
+// Samples must be ordered by time.
+func ReadTrace(r io.Reader) ([]Sample, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = 4
+	cr.TrimLeadingSpace = true
+
+	var samples []Sample
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && isHeader(record) {
+			continue
+		}
+		s, err := parseSample(record)
+		if err != nil {
+			return nil, fmt.Errorf("sim: invalid sample %q: %v", strings.Join(record, ","), err)
+		}
+		if len(samples) > 0 && s.Time < samples[len(samples)-1].Time {
+			return nil, fmt.Errorf("sim: sample %q is out of order", strings.Join(record, ","))
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+func isHeader(record []string) bool {
+	_, err := strconv.ParseFloat(record[1], 64)
+	return err != nil
+}
+
+func parseSample(record []string) (Sample, error) {
+	var (
+		s   Sample
+		err error
+	)
+	if s.Time, err = parseTime(record[0]); err != nil {
+		return s, err
+	}
+	if s.LiveHeap, err = strconv.ParseUint(record[1], 10, 64); err != nil {
+		return s, err
+	}
+	if s.AllocRate, err = strconv.ParseFloat(record[2], 64); err != nil {
+		return s, err
+	}
+	if s.MemoryLimit, err = strconv.ParseUint(record[3], 10, 64); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+func parseTime(s string) (time.Duration, error) {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	return time.ParseDuration(s)
+}
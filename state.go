@@ -0,0 +1,239 @@
+package gogctuner
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"time"
+)
+
+const (
+	stateSaveInterval            = 10 * time.Second
+	defaultRestartStepPercentage = 10
+	defaultRestartDecay          = time.Hour
+	stateFileMode                = 0644
+	stateFileTempSuffix          = ".tmp"
+)
+
+// Restart is the state persisted across restarts, see WithStateFile
+type Restart struct {
+	// Loaded reports whether the state of the previous instance has been loaded
+	Loaded bool
+	// OOM reports whether the previous instance is considered OOM-killed: the oom_kill counter of the memory cgroup
+	// has increased since its last save, or with Config.RestartUncleanExit, it didn't shut down cleanly with the peak
+	// usage above EmergencyRAMPercentage of the memory limit
+	OOM bool
+	// PreviousMaxRAMPercentage and PreviousPeakUsage are the applied MaxRAMPercentage and the peak usage
+	// of the previous instance
+	PreviousMaxRAMPercentage float64
+	PreviousPeakUsage        uint64
+	// PenaltyPercentage is the percentage points MaxRAMPercentage is lowered by because of the OOM kills
+	// of the previous instances, it decays to 0 over Config.RestartDecay since PenaltySince
+	PenaltyPercentage float64
+	PenaltySince      time.Time
+	// PeakUsage is the peak memory usage of the process seen by the tuner
+	PeakUsage uint64
+	// LastSave is the time of the last save of the state
+	LastSave time.Time
+	// Error is the error of the latest loading or saving of the state
+	Error string
+}
+
+// tunerState is the content of the state file
+type tunerState struct {
+	Time             time.Time `json:"time"`
+	Clean            bool      `json:"clean,omitempty"`
+	MaxRAMPercentage float64   `json:"max_ram_percentage,omitempty"`
+	GOGC             int       `json:"gogc,omitempty"`
+	MemoryLimit      uint64    `json:"memory_limit,omitempty"`
+	PeakUsage        uint64    `json:"peak_usage,omitempty"`
+	OOMKill          *uint64   `json:"oom_kill,omitempty"` // nil if the memory cgroup events are unavailable
+	Penalty          float64   `json:"penalty,omitempty"`
+	PenaltySince     time.Time `json:"penalty_since,omitempty"`
+}
+
+// restart is the state of the restart penalty, it's guarded by stateMu
+type restart struct {
+	pending bool // the previous instance is OOM-killed, the step is added by the next applyRestartPenalty
+	penalty float64
+	since   time.Time
+	peak    uint64
+}
+
+// WithStateFile persists the tuner state to the file at path periodically, and on Shutdown.
+// On startup, if the previous instance is considered OOM-killed, MaxRAMPercentage is lowered by
+// Config.RestartStepPercentage, and the penalty decays over Config.RestartDecay, see Status.Restart.
+func WithStateFile(path string) Option {
+	return func(o *opts) {
+		o.stateFile = path
+	}
+}
+
+// Shutdown saves the state of the tuner enabled by EnableGCTuner as a clean shutdown, see WithStateFile,
+// and stops the tuner, the GC parameters are kept as is. It's intended to be called when the process exits gracefully.
+func Shutdown() {
+	h, _ := globalHandler.Load().(*adaptiveGCHandler)
+	if h == nil {
+		return
+	}
+	if h.stateFile != "" {
+		h.saveState(true)
+	}
+	h.Stop()
+}
+
+// loadState loads the state of the previous instance and detects whether it's OOM-killed
+func (a *adaptiveGCHandler) loadState() {
+	data, err := ioutil.ReadFile(a.stateFile)
+	if os.IsNotExist(err) {
+		return
+	}
+	var prev tunerState
+	if err == nil {
+		err = json.Unmarshal(data, &prev)
+	}
+	if err != nil {
+		a.logger.Errorf("gctuner: failed to load the state file %s: %v", a.stateFile, err)
+		a.updateStatus(func(s *Status) {
+			s.Restart.Error = err.Error()
+		})
+		return
+	}
+
+	config, _ := a.configurator.GetConfig()
+	oom := false
+	if events, err := a.rt.ReadMemoryEvents(); err == nil && prev.OOMKill != nil && events.OOMKill > *prev.OOMKill {
+		oom = true
+	}
+	if config.RestartUncleanExit && !prev.Clean && prev.MemoryLimit > 0 &&
+		float64(prev.PeakUsage) >= config.emergencyPercentage()/100*float64(prev.MemoryLimit) {
+		oom = true
+	}
+	if oom {
+		a.logger.Logf("gctuner: the previous instance is considered OOM-killed at max_ram_percentage %.2f, peak usage %s",
+			prev.MaxRAMPercentage, printMemorySize(prev.PeakUsage))
+	}
+
+	a.stateMu.Lock()
+	a.restart = restart{pending: oom, penalty: prev.Penalty, since: prev.PenaltySince}
+	a.stateMu.Unlock()
+	a.updateStatus(func(s *Status) {
+		r := &s.Restart
+		r.Loaded, r.OOM = true, oom
+		r.PreviousMaxRAMPercentage, r.PreviousPeakUsage = prev.MaxRAMPercentage, prev.PeakUsage
+	})
+}
+
+// saveState saves the state to the state file, clean is set on the graceful shutdown
+func (a *adaptiveGCHandler) saveState(clean bool) {
+	applied, _ := a.appliedConfig.Load().(Config)
+	now := a.rt.Now()
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	a.samplePeakUsageLocked()
+	st := tunerState{
+		Time:             now,
+		Clean:            clean,
+		MaxRAMPercentage: applied.MaxRAMPercentage,
+		GOGC:             applied.GOGC,
+		MemoryLimit:      a.rt.ReadMemoryLimit(),
+		PeakUsage:        a.restart.peak,
+		Penalty:          a.restart.penalty,
+		PenaltySince:     a.restart.since,
+	}
+	if events, err := a.rt.ReadMemoryEvents(); err == nil {
+		st.OOMKill = &events.OOMKill
+	}
+
+	err := writeState(a.stateFile, st)
+	if err != nil {
+		a.logger.Errorf("gctuner: failed to save the state file %s: %v", a.stateFile, err)
+	}
+	a.updateStatus(func(s *Status) {
+		s.Restart.PeakUsage = st.PeakUsage
+		if err != nil {
+			s.Restart.Error = err.Error()
+			return
+		}
+		s.Restart.LastSave, s.Restart.Error = now, ""
+	})
+}
+
+// writeState writes the state to a temporary file and renames it, so that the state file is never partially written
+func writeState(path string, st tunerState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	tmp := path + stateFileTempSuffix
+	if err = ioutil.WriteFile(tmp, data, stateFileMode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (a *adaptiveGCHandler) samplePeakUsageLocked() {
+	if usage := a.readUsage(); usage > a.restart.peak {
+		a.restart.peak = usage
+	}
+}
+
+// watchState saves the state periodically
+func (a *adaptiveGCHandler) watchState() {
+	if a.stateFile == "" {
+		return
+	}
+	ticker := a.rt.NewTicker(stateSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			a.saveState(false)
+		case <-a.done:
+			return
+		}
+	}
+}
+
+// applyRestartPenalty lowers MaxRAMPercentage by the decaying penalty of the OOM kills of the previous instances
+func (a *adaptiveGCHandler) applyRestartPenalty(config Config) Config {
+	if a.stateFile == "" || config.MaxRAMPercentage <= 0 {
+		return config
+	}
+	now := a.rt.Now()
+	a.stateMu.Lock()
+	r := &a.restart
+	if r.pending {
+		step := config.RestartStepPercentage
+		if step == 0 {
+			step = defaultRestartStepPercentage
+		}
+		maxStep := config.MaxRAMPercentage / 2
+		if config.RestartMaxStepPercentage > 0 {
+			maxStep = math.Min(config.RestartMaxStepPercentage, maxStep)
+		}
+		r.pending = false
+		r.penalty = math.Min(restartPenalty(r.penalty, r.since, now, config)+step, maxStep)
+		r.since = now
+		a.logger.Logf("gctuner: lower max_ram_percentage by %.2f due to the OOM kill of the previous instance", r.penalty)
+	}
+	penalty := restartPenalty(r.penalty, r.since, now, config)
+	since := r.since
+	a.stateMu.Unlock()
+
+	a.updateStatus(func(s *Status) {
+		s.Restart.PenaltyPercentage, s.Restart.PenaltySince = penalty, since
+	})
+	config.MaxRAMPercentage -= math.Min(penalty, config.MaxRAMPercentage/2)
+	return config
+}
+
+// restartPenalty returns the penalty decayed linearly over Config.RestartDecay since the given time
+func restartPenalty(penalty float64, since, now time.Time, config Config) float64 {
+	decay := config.RestartDecay
+	if decay == 0 {
+		decay = defaultRestartDecay
+	}
+	return decayPenalty(penalty, since, now, decay)
+}
@@ -0,0 +1,126 @@
+package gogctuner
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestRestartPenalty(t *testing.T) {
+	since := gctunertest.Epoch
+	f := func(penalty float64, elapsed time.Duration, config Config, want float64) {
+		t.Helper()
+		if got := restartPenalty(penalty, since, since.Add(elapsed), config); math.Abs(got-want) > 1e-9 {
+			t.Fatalf("unexpected penalty %.2f after %v, got: %.2f, want %.2f", penalty, elapsed, got, want)
+		}
+	}
+	f(0, 0, Config{}, 0)
+	f(10, 0, Config{}, 10)
+	f(10, 30*time.Minute, Config{}, 5)
+	f(10, 2*time.Hour, Config{}, 0)
+	f(10, 30*time.Minute, Config{RestartDecay: 2 * time.Hour}, 7.5)
+	f(10, -time.Minute, Config{}, 10)
+}
+
+func TestStatePersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gctuner")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	config := Config{MaxRAMPercentage: 80, RestartMaxStepPercentage: 15}
+	now := gctunertest.Epoch
+	var h *adaptiveGCHandler
+	// start starts a new instance
+	start := func(oomKill uint64) *gctunertest.Runtime {
+		rt := gctunertest.NewRuntime()
+		rt.SetDetectedMemoryLimit(1000 << 20)
+		rt.SetMemoryEvents(gctunertest.MemoryEvents{OOMKill: oomKill})
+		rt.Advance(now.Sub(gctunertest.Epoch))
+		h = newAdaptiveGCHandler(&opts{
+			configurator: staticConfigurator{config: config}, logger: &testLogger{}, runtime: rt, stateFile: path,
+		})
+		h.loadState()
+		h.checkAndSetNextGCConfig()
+		return rt
+	}
+	f := func(oom bool, maxRAMPercentage float64) {
+		t.Helper()
+		s := h.Status()
+		if s.Restart.OOM != oom || math.Abs(s.AppliedConfig.MaxRAMPercentage-maxRAMPercentage) > 1e-9 {
+			t.Fatalf("unexpected applied MaxRAMPercentage, got: %.2f (%+v), want %.2f (OOM %v)",
+				s.AppliedConfig.MaxRAMPercentage, s.Restart, maxRAMPercentage, oom)
+		}
+	}
+
+	rt := start(1)
+	if h.Status().Restart.Loaded {
+		t.Fatalf("expecting no state to be loaded")
+	}
+	f(false, 80)
+	h.saveState(false)
+
+	// the oom_kill counter increases
+	rt = start(2)
+	f(true, 70)
+	rt.Advance(30 * time.Minute)
+	now = now.Add(30 * time.Minute)
+	h.checkAndSetNextGCConfig()
+	f(true, 75) // decaying
+	h.saveState(true)
+
+	// a clean shutdown
+	start(2)
+	f(false, 75)
+	if s := h.Status().Restart; !s.Loaded || s.PreviousMaxRAMPercentage != 75 {
+		t.Fatalf("unexpected restart status, got: %+v", s)
+	}
+
+	// the peak usage was close to the memory limit without a clean shutdown, but the oom_kill counter didn't increase
+	rt = start(2)
+	rt.SetRSS(960 << 20)
+	h.saveState(false)
+	start(2)
+	f(false, 75)
+	if s := h.Status().Restart; s.PreviousPeakUsage != 960<<20 {
+		t.Fatalf("unexpected restart status, got: %+v", s)
+	}
+
+	// the penalties add up to the maximum
+	start(3)
+	f(true, 65)
+	if s := h.Status().Restart; s.PenaltyPercentage != 15 {
+		t.Fatalf("unexpected restart status, got: %+v", s)
+	}
+	h.saveState(true)
+
+	// with RestartUncleanExit, the peak usage close to the memory limit without a clean shutdown is an OOM kill
+	config.RestartUncleanExit = true
+	rt = start(3)
+	f(false, 65)
+	rt.SetRSS(960 << 20)
+	h.saveState(false)
+	rt = start(3)
+	f(true, 65)
+	rt.SetRSS(960 << 20)
+	h.saveState(true)
+	start(3)
+	f(false, 65)
+
+	// a corrupted state file
+	if err = ioutil.WriteFile(path, []byte("{"), stateFileMode); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	start(2)
+	f(false, 80)
+	if s := h.Status().Restart; s.Loaded || s.Error == "" {
+		t.Fatalf("expecting an error, got: %+v", s)
+	}
+}
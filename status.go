@@ -0,0 +1,75 @@
+package gogctuner
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Status is a snapshot of the tuner state, it's intended for monitoring, e.g. to be exported as metrics.
+type Status struct {
+	// Enabled reports whether gctuner is enabled
+	Enabled bool
+	// Config is the latest valid config of the configurator
+	Config Config
+	// Overrides is the number of the active overrides, see Override
+	Overrides int
+	// AppliedConfig is the config applied to the GC after the adjustments of the signals, e.g. memory pressure
+	AppliedConfig Config
+	// MemoryPressure is the memory pressure signal, see Config.PressureThreshold
+	MemoryPressure MemoryPressure
+	// MemoryEvents is the memory cgroup events signal, see Config.OOMStepPercentage
+	MemoryEvents MemoryEvents
+	// NonGoMemory is the memory not managed by the Go runtime, see Config.AccountNonGoMemory
+	NonGoMemory NonGoMemory
+	// OffHeap is the memory reported by the sources registered by RegisterOffHeapSource, sorted by name
+	OffHeap []OffHeapSource
+	// HeapPressure is the latest heap pressure level, see OnPressure
+	HeapPressure PressureInfo
+	// IdleReturn is the memory returned to the OS when the process is idle, see Config.IdleReturnInterval
+	IdleReturn IdleReturn
+	// ForcedGCs is the number of GC cycles forced by Config.MaxGCInterval
+	ForcedGCs uint64
+	// LastForcedGC is the time of the last GC cycle forced by Config.MaxGCInterval
+	LastForcedGC time.Time
+	// Trigger is the statistics of the re-evaluation triggers, see WithTrigger
+	Trigger TriggerStats
+	// Forecast is the forecast of the predictive mode, see Config.Predictive
+	Forecast Forecast
+	// Ramp is the progress of the transition to a new config, see Config.RampDuration
+	Ramp Ramp
+	// Rollback is the regression guard of new configs, see Config.RollbackWindow
+	Rollback Rollback
+	// Restart is the state persisted across restarts, see WithStateFile
+	Restart Restart
+	// Tier is the tier selected by the memory limit, see Config.Tiers
+	Tier TierSelection
+	// MemoryRequest is the memory request of the container, see WithMemoryRequest
+	MemoryRequest MemoryRequest
+}
+
+var globalHandler atomic.Value // *adaptiveGCHandler
+
+// GetStatus returns the status of the tuner enabled by EnableGCTuner
+func GetStatus() Status {
+	h, _ := globalHandler.Load().(*adaptiveGCHandler)
+	if h == nil {
+		return Status{}
+	}
+	return h.Status()
+}
+
+// Status returns the status of the handler
+func (a *adaptiveGCHandler) Status() Status {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	s := a.status
+	s.Enabled = true
+	s.Trigger.HookCalls = uint64(atomic.LoadUint32(&a.numGC))
+	return s
+}
+
+func (a *adaptiveGCHandler) updateStatus(f func(s *Status)) {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	f(&a.status)
+}
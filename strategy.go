@@ -0,0 +1,114 @@
+package gogctuner
+
+import (
+	"math"
+)
+
+type (
+	// StrategyInput is the memory state observed by the tuner when it re-evaluates the GC parameters.
+	StrategyInput struct {
+		// MemoryLimit is the detected memory limit in bytes, e.g. the cgroup memory limit or the host memory size.
+		MemoryLimit uint64
+		// LiveHeap is the live dataset size in bytes, see memory.GetLiveDatasetSize.
+		LiveHeap uint64
+		// DefaultGOGC is the GOGC used when Config.GOGC is not set, i.e. the GOGC environment variable, 100 if zero.
+		DefaultGOGC int
+		// PreviousGOGC is the GOGC in effect, it's used to smooth the GOGC adjustments, 0 if unknown.
+		PreviousGOGC int
+		// SameCycle reports whether no GC cycle has completed since PreviousGOGC was decided, e.g. on the periodic
+		// re-evaluations, the smoothing steps are taken per GC cycle, so PreviousGOGC is kept then.
+		SameCycle bool
+	}
+
+	// Decision is the set of GC parameters chosen by a Strategy.
+	Decision struct {
+		// GOGC is the value for debug.SetGCPercent, -1 turns off the GOGC based GC.
+		GOGC int
+		// MemoryLimit is the value for debug.SetMemoryLimit, math.MaxInt64 means no limit.
+		MemoryLimit int64
+	}
+
+	// Strategy computes the GC parameters for a Config under the observed memory state.
+	// A Strategy must be a pure function of its arguments, so that it can be replayed offline, see package sim.
+	Strategy interface {
+		Decide(config Config, input StrategyInput) Decision
+	}
+
+	// StrategyFunc is an adapter to allow the use of ordinary functions as Strategy.
+	StrategyFunc func(config Config, input StrategyInput) Decision
+)
+
+var (
+	// GOGCStrategy adjusts GOGC on every GC cycle according to the live heap size and the target memory limit,
+	// it's the strategy used before go1.19.
+	GOGCStrategy Strategy = StrategyFunc(decideGOGC)
+
+	// MemoryLimitStrategy sets the soft memory limit to MaxRAMPercentage of the memory limit,
+	// it's the strategy used in go1.19 and above.
+	MemoryLimitStrategy Strategy = StrategyFunc(decideMemoryLimit)
+)
+
+// Decide calls f(config, input).
+func (f StrategyFunc) Decide(config Config, input StrategyInput) Decision {
+	return f(config, input)
+}
+
+// DefaultStrategy returns the strategy used by gctuner with the current Go version.
+func DefaultStrategy() Strategy {
+	return defaultStrategy
+}
+
+func decideGOGC(config Config, input StrategyInput) Decision {
+	if config.MaxRAMPercentage <= 0 {
+		return Decision{GOGC: gogcOrDefault(config.GOGC, input), MemoryLimit: math.MaxInt64}
+	}
+	maxGOGC := goGCNoLimit
+	if config.GOGC > 0 {
+		maxGOGC = float64(config.GOGC)
+	}
+	liveSize := math.Max(config.heapFloor(), float64(input.LiveHeap))
+	minGOGC := config.gogcFloor()
+	gogc := getGOGC(config.MaxRAMPercentage, input.MemoryLimit, liveSize, maxGOGC, defaultGOGC(input),
+		minGOGC, config.emergencyPercentage())
+	tight := calculateGOGC(config.MaxRAMPercentage, input.MemoryLimit, liveSize) < float64(minGOGC)
+	return Decision{
+		GOGC:        int(math.Min(float64(smoothGOGC(input.PreviousGOGC, gogc, tight, input.SameCycle, config)), maxGOGC)),
+		MemoryLimit: math.MaxInt64,
+	}
+}
+
+func decideMemoryLimit(config Config, input StrategyInput) Decision {
+	if config.MaxRAMPercentage <= 0 {
+		return Decision{GOGC: gogcOrDefault(config.GOGC, input), MemoryLimit: math.MaxInt64}
+	}
+	gogc := config.GOGC
+	if gogc == 0 { // gogc is not set
+		gogc = -1 // Disable GC unless the memory limit is reached
+	}
+	limit := config.MaxRAMPercentage / 100.0 * float64(input.MemoryLimit)
+	// Like getGOGC, if the live heap leaves no room for MinGOGC or MinHeapSize under the limit, the GC would run
+	// continuously, so the limit is raised for them up to EmergencyRAMPercentage of the memory limit
+	need := math.Max(float64(input.LiveHeap)*(1+float64(config.gogcFloor())/100), config.heapFloor())
+	if need > limit {
+		limit = math.Min(need, math.Max(config.emergencyPercentage()/100*float64(input.MemoryLimit), limit))
+	}
+	return Decision{
+		GOGC:        gogc,
+		MemoryLimit: int64(limit),
+	}
+}
+
+// gogcOrDefault returns gogc if it's specified, otherwise the default GOGC of the input.
+func gogcOrDefault(gogc int, input StrategyInput) int {
+	if gogc != 0 {
+		return gogc
+	}
+	return defaultGOGC(input)
+}
+
+func defaultGOGC(input StrategyInput) int {
+	if input.DefaultGOGC == 0 {
+		return 100
+	}
+	return input.DefaultGOGC
+}
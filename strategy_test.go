@@ -0,0 +1,55 @@
+package gogctuner
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStrategies(t *testing.T) {
+	f := func(s Strategy, config Config, input StrategyInput, want Decision) {
+		t.Helper()
+		got := s.Decide(config, input)
+		if got != want {
+			t.Fatalf("unexpected decision for %+v, got: %+v, want %+v", config, got, want)
+		}
+	}
+	input := StrategyInput{MemoryLimit: 10000 << 20, LiveHeap: 1000 << 20}
+	f(GOGCStrategy, Config{GOGC: 200}, input, Decision{GOGC: 200, MemoryLimit: math.MaxInt64})
+	f(GOGCStrategy, Config{MaxRAMPercentage: 80}, input, Decision{GOGC: 700, MemoryLimit: math.MaxInt64})
+	f(GOGCStrategy, Config{MaxRAMPercentage: 80, GOGC: 300}, input, Decision{GOGC: 300, MemoryLimit: math.MaxInt64})
+
+	// smoothing of GOGCStrategy
+	smooth := Config{MaxRAMPercentage: 80, GOGCMaxStepUp: 50, GOGCMaxStepDown: 100}
+	input.PreviousGOGC = 100
+	f(GOGCStrategy, smooth, input, Decision{GOGC: 150, MemoryLimit: math.MaxInt64})
+	input.SameCycle = true // no step without a GC cycle
+	f(GOGCStrategy, smooth, input, Decision{GOGC: 100, MemoryLimit: math.MaxInt64})
+	input.SameCycle = false
+	smooth.GOGC = 120
+	f(GOGCStrategy, smooth, input, Decision{GOGC: 120, MemoryLimit: math.MaxInt64})
+	smooth.GOGC = 0
+	input.PreviousGOGC = 900
+	f(GOGCStrategy, smooth, input, Decision{GOGC: 800, MemoryLimit: math.MaxInt64})
+	f(GOGCStrategy, smooth, StrategyInput{MemoryLimit: 10000 << 20, LiveHeap: 7800 << 20, PreviousGOGC: 900},
+		Decision{GOGC: minGOGCValue, MemoryLimit: math.MaxInt64})
+	input.PreviousGOGC = 0
+
+	// bounds
+	f(GOGCStrategy, Config{MaxRAMPercentage: 80, MinHeapSize: 2000 << 20}, input, Decision{GOGC: 300, MemoryLimit: math.MaxInt64})
+	f(GOGCStrategy, Config{MaxRAMPercentage: 80, MinGOGC: 10},
+		StrategyInput{MemoryLimit: 10000 << 20, LiveHeap: 7800 << 20}, Decision{GOGC: 10, MemoryLimit: math.MaxInt64})
+	f(MemoryLimitStrategy, Config{MaxRAMPercentage: 80, GOGC: 30, MinGOGC: 10}, input, Decision{GOGC: 30, MemoryLimit: 8000 << 20})
+	// the live heap with MinGOGC exceeds the limit, which is raised up to EmergencyRAMPercentage
+	f(MemoryLimitStrategy, Config{MaxRAMPercentage: 80}, StrategyInput{MemoryLimit: 10000 << 20, LiveHeap: 6000 << 20},
+		Decision{GOGC: -1, MemoryLimit: 9000 << 20})
+	f(MemoryLimitStrategy, Config{MaxRAMPercentage: 80, MinGOGC: 10}, StrategyInput{MemoryLimit: 10000 << 20, LiveHeap: 8000 << 20},
+		Decision{GOGC: -1, MemoryLimit: 8800 << 20})
+	f(MemoryLimitStrategy, Config{MaxRAMPercentage: 80}, StrategyInput{MemoryLimit: 10000 << 20, LiveHeap: 8000 << 20},
+		Decision{GOGC: -1, MemoryLimit: 9500 << 20})
+	f(MemoryLimitStrategy, Config{MaxRAMPercentage: 1, MinHeapSize: 200 << 20}, StrategyInput{MemoryLimit: 10000 << 20},
+		Decision{GOGC: -1, MemoryLimit: 200 << 20})
+
+	f(MemoryLimitStrategy, Config{GOGC: 200}, input, Decision{GOGC: 200, MemoryLimit: math.MaxInt64})
+	f(MemoryLimitStrategy, Config{MaxRAMPercentage: 80}, input, Decision{GOGC: -1, MemoryLimit: 8000 << 20})
+	f(MemoryLimitStrategy, Config{MaxRAMPercentage: 80, GOGC: 300}, input, Decision{GOGC: 300, MemoryLimit: 8000 << 20})
+}
@@ -0,0 +1,110 @@
+package gogctuner
+
+import (
+	"fmt"
+	"math"
+)
+
+// MemoryTier is a rule of Config.Tiers, it selects MaxRAMPercentage for the memory limits below MaxLimit
+type MemoryTier struct {
+	// MaxLimit is the exclusive upper bound of the memory limit in bytes of the tier, 0 means no bound
+	MaxLimit uint64 `json:"max_limit,omitempty" yaml:"max_limit,omitempty"`
+	// MaxRAMPercentage is the MaxRAMPercentage of the tier, range (0, 100]
+	MaxRAMPercentage float64 `json:"max_ram_percentage,omitempty" yaml:"max_ram_percentage,omitempty"`
+	// Headroom is the memory in bytes kept free for the fixed overhead, e.g. the goroutine stacks and the sidecars,
+	// the percentage is capped by (limit - Headroom) / limit if specified, MaxRAMPercentage can be 0 then
+	Headroom uint64 `json:"headroom,omitempty" yaml:"headroom,omitempty"`
+}
+
+// TierSelection is the tier of Config.Tiers selected by the memory limit
+type TierSelection struct {
+	// Selected reports whether a tier is selected, Index is the index of the tier in Config.Tiers
+	Selected bool
+	Index    int
+	// MemoryLimit is the memory limit the tier is selected by
+	MemoryLimit uint64
+	// MaxRAMPercentage is the MaxRAMPercentage of the tier for the memory limit
+	MaxRAMPercentage float64
+}
+
+// ForMemoryLimit returns the config with MaxRAMPercentage selected by Tiers for the memory limit and Tiers cleared,
+// the config is returned as is without Tiers, and MaxRAMPercentage is kept if no tier matches.
+func (c Config) ForMemoryLimit(limit uint64) Config {
+	c, _ = c.selectTier(limit)
+	return c
+}
+
+// selectTier returns the config with the tier of the limit applied, and the index of the tier, -1 if none
+func (c Config) selectTier(limit uint64) (Config, int) {
+	if len(c.Tiers) == 0 {
+		return c, -1
+	}
+	tiers := c.Tiers
+	c.Tiers = nil
+	if limit == 0 {
+		return c, -1
+	}
+	for i, t := range tiers {
+		if t.MaxLimit != 0 && limit >= t.MaxLimit {
+			continue
+		}
+		pct := t.MaxRAMPercentage
+		if pct == 0 {
+			pct = 100
+		}
+		if t.Headroom > 0 {
+			// keep a minimal heap like applyNonGoMemory
+			floor := math.Min(100*c.heapFloor()/float64(limit), pct)
+			pct = math.Max(math.Min(pct, 100*(float64(limit)-float64(t.Headroom))/float64(limit)), floor)
+		}
+		c.MaxRAMPercentage = pct
+		return c, i
+	}
+	return c, -1
+}
+
+// checkTiers validates Config.Tiers
+func (c *Config) checkTiers() error {
+	for i, t := range c.Tiers {
+		if t.MaxRAMPercentage < 0 || t.MaxRAMPercentage > 100 {
+			return fmt.Errorf("invalid tiers[%d].max_ram_percentage value: %f, expected range (0, 100]", i, t.MaxRAMPercentage)
+		}
+		if t.MaxRAMPercentage == 0 && t.Headroom == 0 {
+			return fmt.Errorf("invalid tiers[%d], expected max_ram_percentage or headroom", i)
+		}
+		if c.EmergencyRAMPercentage > 0 && t.MaxRAMPercentage > c.EmergencyRAMPercentage {
+			return fmt.Errorf("invalid tiers[%d].max_ram_percentage value: %f, expected not above emergency_ram_percentage %f",
+				i, t.MaxRAMPercentage, c.EmergencyRAMPercentage)
+		}
+		if i == 0 {
+			continue
+		}
+		if prev := c.Tiers[i-1].MaxLimit; prev == 0 || (t.MaxLimit != 0 && t.MaxLimit <= prev) {
+			return fmt.Errorf("invalid tiers[%d].max_limit value: %d, expected ascending and only the last one unbounded",
+				i, t.MaxLimit)
+		}
+	}
+	return nil
+}
+
+// applyTiers selects MaxRAMPercentage by the detected memory limit, and reports whether the limit has changed
+// since the last call
+func (a *adaptiveGCHandler) applyTiers(config Config) (Config, bool) {
+	limit, _ := getMemoryLimit(a.rt, config)
+	changed := a.memoryLimit != 0 && limit != a.memoryLimit
+	a.memoryLimit = limit
+
+	config, index := config.selectTier(limit)
+	selection := TierSelection{Selected: index >= 0, Index: index, MemoryLimit: limit, MaxRAMPercentage: config.MaxRAMPercentage}
+	if !selection.Selected {
+		selection = TierSelection{}
+	}
+	a.updateStatus(func(s *Status) {
+		if s.Tier != selection && selection.Selected {
+			a.logger.Logf("gctuner: memory limit %s selects tier %d, max_ram_percentage %.2f",
+				printMemorySize(limit), index, selection.MaxRAMPercentage)
+		}
+		s.Tier = selection
+	})
+	return config, changed
+}
@@ -0,0 +1,79 @@
+package gogctuner
+
+import (
+	"math"
+	"testing"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestForMemoryLimit(t *testing.T) {
+	f := func(config Config, limit uint64, want float64) {
+		t.Helper()
+		got := config.ForMemoryLimit(limit)
+		if math.Abs(got.MaxRAMPercentage-want) > 1e-9 || len(got.Tiers) != 0 {
+			t.Fatalf("unexpected config for the limit %d, got: %+v, want MaxRAMPercentage %.2f", limit, got, want)
+		}
+	}
+	tiered := Config{MaxRAMPercentage: 80, Tiers: []MemoryTier{
+		{MaxLimit: 1 << 30, MaxRAMPercentage: 70},
+		{MaxLimit: 8 << 30, MaxRAMPercentage: 85},
+		{MaxRAMPercentage: 92},
+	}}
+	f(Config{MaxRAMPercentage: 80}, 512<<20, 80)
+	f(tiered, 512<<20, 70)
+	f(tiered, 1<<30, 85)
+	f(tiered, 32<<30, 92)
+	f(tiered, 0, 80) // the limit is unknown
+
+	// no tier matches
+	f(Config{MaxRAMPercentage: 80, Tiers: tiered.Tiers[:2]}, 32<<30, 80)
+
+	// the percentage with the absolute headroom
+	headroom := Config{Tiers: []MemoryTier{{MaxRAMPercentage: 90, Headroom: 200 << 20}}}
+	f(headroom, 1000<<20, 80)
+	f(headroom, 10000<<20, 90)
+	f(Config{Tiers: []MemoryTier{{Headroom: 100 << 20}}}, 1000<<20, 90)
+	f(headroom, 100<<20, 4) // the minimal heap is kept
+}
+
+func TestCheckTiers(t *testing.T) {
+	f := func(tiers []MemoryTier, expectValid bool) {
+		t.Helper()
+		config := Config{MaxRAMPercentage: 80, Tiers: tiers}
+		if err := config.CheckValid(); (err == nil) != expectValid {
+			t.Fatalf("unexpected validity of %+v, got error: %v, want valid %v", tiers, err, expectValid)
+		}
+	}
+	f(nil, true)
+	f([]MemoryTier{{MaxLimit: 1 << 30, MaxRAMPercentage: 70}, {MaxLimit: 8 << 30, MaxRAMPercentage: 85}, {MaxRAMPercentage: 92}}, true)
+	f([]MemoryTier{{Headroom: 100 << 20}}, true)
+	f([]MemoryTier{{MaxLimit: 1 << 30}}, false)
+	f([]MemoryTier{{MaxRAMPercentage: 101}}, false)
+	f([]MemoryTier{{MaxRAMPercentage: 70}, {MaxRAMPercentage: 80}}, false)
+	f([]MemoryTier{{MaxLimit: 8 << 30, MaxRAMPercentage: 70}, {MaxLimit: 1 << 30, MaxRAMPercentage: 80}}, false)
+}
+
+func TestTiersFollowMemoryLimit(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetDetectedMemoryLimit(512 << 20)
+	h, _ := newTestHandler(rt, staticConfigurator{config: Config{MaxRAMPercentage: 80, Tiers: []MemoryTier{
+		{MaxLimit: 1 << 30, MaxRAMPercentage: 70},
+		{MaxRAMPercentage: 92},
+	}}})
+
+	f := func(limit uint64, maxRAMPercentage float64, index int) {
+		t.Helper()
+		rt.SetDetectedMemoryLimit(limit)
+		h.checkAndSetNextGCConfig()
+		s := h.Status()
+		if s.AppliedConfig.MaxRAMPercentage != maxRAMPercentage || !s.Tier.Selected || s.Tier.Index != index ||
+			s.Tier.MemoryLimit != limit {
+			t.Fatalf("unexpected applied MaxRAMPercentage for the limit %d, got: %.2f (%+v), want %.2f (tier %d)",
+				limit, s.AppliedConfig.MaxRAMPercentage, s.Tier, maxRAMPercentage, index)
+		}
+	}
+	f(512<<20, 70, 0)
+	f(32<<30, 92, 1)
+	f(768<<20, 70, 0)
+}
@@ -0,0 +1,142 @@
+package gogctuner
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const defaultPollInterval = 100 * time.Millisecond
+
+// Trigger is the source of the re-evaluations of the GC parameters, see WithTrigger.
+// The GC parameters are also re-evaluated periodically and on config updates regardless of the trigger.
+type Trigger int
+
+const (
+	// TriggerGCHook re-evaluates after every GC cycle by a finalizer-based hook,
+	// which depends on the finalizer goroutine not being blocked by other finalizers.
+	TriggerGCHook Trigger = 1 << iota
+	// TriggerTicker polls /gc/cycles/total:gc-cycles and re-evaluates once a GC cycle completes,
+	// it re-evaluates on every tick before go1.16.
+	TriggerTicker
+	// TriggerBoth uses both TriggerGCHook and TriggerTicker, the ticker catches the GC cycles missed by the hook.
+	TriggerBoth = TriggerGCHook | TriggerTicker
+)
+
+func (t Trigger) String() string {
+	switch t {
+	case TriggerGCHook:
+		return "gc_hook"
+	case TriggerTicker:
+		return "ticker"
+	case TriggerBoth:
+		return "both"
+	}
+	return fmt.Sprintf("Trigger(%d)", int(t))
+}
+
+// TriggerStats is the statistics of the re-evaluation triggers
+type TriggerStats struct {
+	Trigger Trigger
+	// HookCalls is the number of the GC cycles seen by the GC hook
+	HookCalls uint64
+	// TickerGCs is the number of the GC cycles seen by the ticker
+	TickerGCs uint64
+	// MissedGCs is the number of the GC cycles missed by the GC hook and caught by the ticker
+	MissedGCs uint64
+	// LastLag is the lag between the completion of the last GC cycle and the re-evaluation
+	LastLag time.Duration
+	// MaxLag is the maximum lag
+	MaxLag time.Duration
+}
+
+// pollState is the state of the ticker trigger
+type pollState struct {
+	numGC, baseNumGC uint64
+	baseHookCalls    uint32
+}
+
+// pollGC polls the number of GC cycles, and triggers a re-evaluation once it changes
+func (a *adaptiveGCHandler) pollGC() {
+	t := a.rt.NewTicker(a.pollInterval)
+	defer t.Stop()
+	var s pollState
+	s.numGC, _ = a.rt.ReadMetric(gcCyclesMetric)
+	s.baseNumGC, s.baseHookCalls = s.numGC, atomic.LoadUint32(&a.numGC)
+	for {
+		select {
+		case <-t.C():
+		case <-a.done:
+			return
+		}
+		a.poll(&s)
+	}
+}
+
+func (a *adaptiveGCHandler) poll(s *pollState) {
+	numGC, err := a.rt.ReadMetric(gcCyclesMetric)
+	if err != nil {
+		a.notify()
+		return
+	}
+	if a.trigger&TriggerGCHook != 0 {
+		// the GC cycles completed before the last poll are missed if the hook hasn't seen them until now
+		hookCalls := uint64(atomic.LoadUint32(&a.numGC) - s.baseHookCalls)
+		if completed := s.numGC - s.baseNumGC; completed > hookCalls {
+			a.updateStatus(func(st *Status) {
+				if missed := completed - hookCalls; missed > st.Trigger.MissedGCs {
+					st.Trigger.MissedGCs = missed
+				}
+			})
+		}
+	}
+	if numGC == s.numGC {
+		return
+	}
+	a.updateStatus(func(st *Status) {
+		st.Trigger.TickerGCs += numGC - s.numGC
+	})
+	s.numGC = numGC
+	a.notify()
+}
+
+// observeLag records the lag between the completion of the last GC cycle and the re-evaluation,
+// if a GC cycle has completed since the last observation
+func (a *adaptiveGCHandler) observeLag(numGC *uint64) {
+	n := a.readNumGC()
+	if n == *numGC {
+		return
+	}
+	*numGC = n
+	lastGC := a.rt.LastGC()
+	if lastGC.IsZero() {
+		return
+	}
+	lag := a.rt.Now().Sub(lastGC)
+	if lag < 0 {
+		lag = 0
+	}
+	a.updateStatus(func(st *Status) {
+		st.Trigger.LastLag = lag
+		if lag > st.Trigger.MaxLag {
+			st.Trigger.MaxLag = lag
+		}
+	})
+}
+
+// gcCycle identifies the latest completed GC cycle, the number of cycles is unavailable with the ticker before go1.16
+type gcCycle struct {
+	numGC  uint64
+	lastGC time.Time
+}
+
+// newGCCycle reports whether a GC cycle has completed since the last call, the first call counts as one.
+// So the periodic re-evaluations, and a GC cycle seen by both the GC hook and the ticker, don't count as extra cycles.
+func (a *adaptiveGCHandler) newGCCycle() bool {
+	c := gcCycle{numGC: a.readNumGC(), lastGC: a.rt.LastGC()}
+	if a.cycleSeen && c.numGC == a.cycle.numGC && c.lastGC.Equal(a.cycle.lastGC) {
+		return false
+	}
+	a.cycle, a.cycleSeen = c, true
+	return true
+}
@@ -0,0 +1,110 @@
+package gogctuner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fangwentong/gogctuner/gctunertest"
+)
+
+func TestPollGC(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetMetric(gcCyclesMetric, 0)
+	h := newAdaptiveGCHandler(&opts{configurator: staticConfigurator{}, logger: &testLogger{}, runtime: rt, trigger: TriggerBoth})
+	h.installGCHook()
+
+	var s pollState
+	f := func(numGC uint64, hooks int, tickerGCs, missedGCs uint64, notified bool) {
+		t.Helper()
+		for i := 0; i < hooks; i++ {
+			rt.TriggerGC()
+		}
+		select {
+		case <-h.ch:
+		default:
+		}
+		rt.SetMetric(gcCyclesMetric, numGC)
+		h.poll(&s)
+		st := h.Status().Trigger
+		if st.TickerGCs != tickerGCs || st.MissedGCs != missedGCs {
+			t.Fatalf("unexpected trigger stats, got: %+v, want %d ticker GCs, %d missed GCs", st, tickerGCs, missedGCs)
+		}
+		if got := len(h.ch) == 1; got != notified {
+			t.Fatalf("unexpected notification, got: %v, want %v", got, notified)
+		}
+	}
+
+	f(0, 0, 0, 0, false)
+	f(1, 1, 1, 0, true)
+	f(3, 0, 3, 0, true) // the hook hasn't run for the GC cycles yet
+	f(3, 1, 3, 1, false)
+	f(3, 0, 3, 1, false)
+	if st := h.Status().Trigger; st.HookCalls != 2 || st.Trigger != TriggerBoth {
+		t.Fatalf("unexpected trigger stats, got: %+v", st)
+	}
+}
+
+func TestObserveLag(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	h, _ := newTestHandler(rt, staticConfigurator{})
+	h.installGCHook()
+
+	var numGC uint64
+	f := func(lag time.Duration, lastLag, maxLag time.Duration) {
+		t.Helper()
+		rt.TriggerGC()
+		rt.Advance(lag)
+		h.observeLag(&numGC)
+		st := h.Status().Trigger
+		if st.LastLag != lastLag || st.MaxLag != maxLag {
+			t.Fatalf("unexpected lag, got: %v (max %v), want %v (max %v)", st.LastLag, st.MaxLag, lastLag, maxLag)
+		}
+	}
+	f(50*time.Millisecond, 50*time.Millisecond, 50*time.Millisecond)
+	f(10*time.Millisecond, 10*time.Millisecond, 50*time.Millisecond)
+
+	// no GC cycle since the last observation
+	rt.Advance(time.Second)
+	h.observeLag(&numGC)
+	if st := h.Status().Trigger; st.LastLag != 10*time.Millisecond {
+		t.Fatalf("unexpected lag, got: %v", st.LastLag)
+	}
+}
+
+func TestTickerTrigger(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	rt.SetMetric(gcCyclesMetric, 0)
+	h := newAdaptiveGCHandler(&opts{configurator: staticConfigurator{}, logger: &testLogger{}, runtime: rt, trigger: TriggerTicker})
+	h.Start()
+	defer h.Stop()
+
+	for i := 1; i <= 1000 && h.Status().Trigger.TickerGCs == 0; i++ {
+		rt.TriggerGC()
+		rt.SetMetric(gcCyclesMetric, uint64(i))
+		rt.Advance(defaultPollInterval)
+		time.Sleep(time.Millisecond)
+	}
+	if st := h.Status().Trigger; st.TickerGCs == 0 || st.HookCalls != 0 {
+		t.Fatalf("unexpected trigger stats, got: %+v", st)
+	}
+}
+
+func TestNewGCCycle(t *testing.T) {
+	rt := gctunertest.NewRuntime()
+	h, _ := newTestHandler(rt, staticConfigurator{})
+	f := func(want bool) {
+		t.Helper()
+		if got := h.newGCCycle(); got != want {
+			t.Fatalf("unexpected new GC cycle, got: %v, want %v", got, want)
+		}
+	}
+	f(true) // the first call
+	f(false)
+	rt.Advance(time.Second)
+	rt.TriggerGC() // by LastGC without the number of cycles
+	f(true)
+	f(false)
+	rt.SetMetric(gcCyclesMetric, 5)
+	f(true)
+	f(false)
+}
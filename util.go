@@ -3,56 +3,76 @@ package gogctuner
 import (
 	"errors"
 	"fmt"
-	"github.com/fangwentong/gogctuner/internal/memory"
+	"github.com/fangwentong/gogctuner/internal/gcruntime"
 	"math"
-	"os"
 	"reflect"
-	"runtime/debug"
 	"strconv"
+	"time"
 )
 
 const (
+	// the defaults of Config.EmergencyRAMPercentage, Config.MinGOGC and Config.MinHeapSize
 	maxRAMUsagePercentage = 95
 	minGOGCValue          = 50
 	minHeapSize           = 4 << 20 // 4MB
 	goGCNoLimit           = float64(math.MaxInt64)
 )
 
+// gogcFloor returns MinGOGC or its default
+func (c *Config) gogcFloor() int {
+	if c.MinGOGC == 0 {
+		return minGOGCValue
+	}
+	return c.MinGOGC
+}
+
+// emergencyPercentage returns EmergencyRAMPercentage or its default
+func (c *Config) emergencyPercentage() float64 {
+	if c.EmergencyRAMPercentage == 0 {
+		return maxRAMUsagePercentage
+	}
+	return c.EmergencyRAMPercentage
+}
+
+// heapFloor returns MinHeapSize or its default
+func (c *Config) heapFloor() float64 {
+	if c.MinHeapSize == 0 {
+		return minHeapSize
+	}
+	return float64(c.MinHeapSize)
+}
+
 // setGCParameter sets GC parameters
-func adjustGOGCByMemoryLimit(oldConfig, newConfig Config, logger Logger) {
+func adjustGOGCByMemoryLimit(oldConfig, newConfig Config, rt gcruntime.Runtime, logger Logger, sameCycle bool) {
 	if newConfig.MaxRAMPercentage > 0 {
 		// If MaxRAMPercentage is set, adjust GOGC based on the current heap size and the target memory limit
-		maxGOGC := goGCNoLimit
-		if newConfig.GOGC > 0 {
-			maxGOGC = float64(newConfig.GOGC)
-		}
-		getCurrentPercentAndChangeGOGC(newConfig.MaxRAMPercentage, maxGOGC, logger)
+		getCurrentPercentAndChangeGOGC(newConfig, rt, logger, sameCycle)
 		return
 	}
 	if reflect.DeepEqual(oldConfig, newConfig) {
 		return
 	}
 	// If MaxRAMPercentage is not set, set a static GOGC
-	setGOGCOrDefault(newConfig.GOGC, logger)
+	setGOGCOrDefault(newConfig.GOGC, rt, logger)
 }
 
-func setGOGCOrDefault(gogc int, logger Logger) {
+func setGOGCOrDefault(gogc int, rt gcruntime.Runtime, logger Logger) {
 	if gogc != 0 {
 		logger.Logf("gctuner: SetGCPercent %v", gogc)
-		debug.SetGCPercent(gogc)
+		rt.SetGCPercent(gogc)
 		return
 	}
 
 	// Reset GOGC to its default value
-	defaultGOGC := readGOGC()
+	defaultGOGC := readGOGC(rt)
 	logger.Logf("gctuner: SetGCPercent to default: %v", defaultGOGC)
-	debug.SetGCPercent(defaultGOGC)
+	rt.SetGCPercent(defaultGOGC)
 }
 
 // readGOGC reads the GOGC value
 // Copied from runtime.readGOGC
-func readGOGC() int {
-	p := os.Getenv("GOGC")
+func readGOGC(rt gcruntime.Runtime) int {
+	p := rt.Getenv("GOGC")
 	if p == "off" {
 		return -1
 	}
@@ -62,25 +82,37 @@ func readGOGC() int {
 	return 100
 }
 
-func getCurrentPercentAndChangeGOGC(memoryLimitInPercent float64, maxGOGC float64, logger Logger) {
-	totalMemSize, err := getMemoryLimit()
+func getCurrentPercentAndChangeGOGC(config Config, rt gcruntime.Runtime, logger Logger, sameCycle bool) {
+	totalMemSize, err := getMemoryLimit(rt, config)
 	if err != nil {
 		logger.Errorf("gctuner: failed to adjust GC, get memory limit err: %v", err.Error())
 		return
 	}
 
-	liveHeapSize := memory.GetLiveDatasetSize()
-
-	liveSize := math.Max(minHeapSize, float64(liveHeapSize))
-	newgogc := getGOGC(memoryLimitInPercent, totalMemSize, liveSize, maxGOGC)
+	liveHeapSize := rt.LiveDatasetSize()
+
+	prevGOGC := rt.GCPercent()
+	d := GOGCStrategy.Decide(config, StrategyInput{
+		MemoryLimit:  totalMemSize,
+		LiveHeap:     liveHeapSize,
+		DefaultGOGC:  readGOGC(rt),
+		PreviousGOGC: prevGOGC,
+		SameCycle:    sameCycle,
+	})
+	if d.GOGC == prevGOGC {
+		return
+	}
 
 	logger.Logf("gctuner: limit %.2f%% (%s). adjusting GOGC to %d, live+unmarked %s",
-		memoryLimitInPercent, printMemorySize(uint64(memoryLimitInPercent/100*float64(totalMemSize))),
-		newgogc, printMemorySize(liveHeapSize))
-	debug.SetGCPercent(newgogc)
+		config.MaxRAMPercentage, printMemorySize(uint64(config.MaxRAMPercentage/100*float64(totalMemSize))),
+		d.GOGC, printMemorySize(liveHeapSize))
+	rt.SetGCPercent(d.GOGC)
 }
 
-func getGOGC(memoryLimitInPercent float64, totalMemSize uint64, liveSize float64, maxGOGC float64) int {
+// getGOGC returns the GOGC to meet the memoryLimitInPercent, within [minGOGC, maxGOGC],
+// and it keeps the heap below the emergencyPercentage if the live heap already exceeds the target.
+func getGOGC(memoryLimitInPercent float64, totalMemSize uint64, liveSize float64, maxGOGC float64, defaultGOGC int,
+	minGOGC int, emergencyPercentage float64) int {
 	// hard_target = live_dataset + live_dataset * (GOGC / 100).
 	// hard_target = memoryLimitInPercent
 	// live_dataset = memPercent
@@ -88,18 +120,44 @@ func getGOGC(memoryLimitInPercent float64, totalMemSize uint64, liveSize float64
 	newgogc := calculateGOGC(memoryLimitInPercent, totalMemSize, liveSize)
 
 	if newgogc > 0 {
-		return int(math.Min(math.Max(newgogc, minGOGCValue), maxGOGC))
+		return int(math.Min(math.Max(newgogc, float64(minGOGC)), maxGOGC))
 	}
 
 	// If the current memory usage has already exceeded the target threshold, it is impossible to reach the target threshold no matter how GOGC is set
 	// When setting the GOGC value, it is necessary to consider constraints such as GC overhead and memory limits, and within these constraints, a smaller GOGC value should be set
-	// Considering GC overhead, it is not advisable to set a too low GOGC value, otherwise, the GC overhead will be high. We set a relatively low GOGC value as a safety net (minGOGCValue = 50)
+	// Considering GC overhead, it is not advisable to set a too low GOGC value, otherwise, the GC overhead will be high. We set a relatively low GOGC value as a safety net (minGOGC, 50 by default)
 	// Considering memory limits, if the maximum allowed memory percentage is maxMemPercent, then the upper limit for GOGC is (maxMemPercent - currentMemPercent) / memPercent * 100.0
-	// Without considering the use of swap memory, the upper limit for maxMemPercent is 100%. If the out-of-memory killer is enabled, maxMemPercent should be reduced appropriately, such as 95%
-	defaultGOGC := readGOGC()
-	maxGOGC = math.Min(maxGOGC, calculateGOGC(maxRAMUsagePercentage, totalMemSize, liveSize))
+	// Without considering the use of swap memory, the upper limit for maxMemPercent is 100%. If the out-of-memory killer is enabled, maxMemPercent should be reduced appropriately, such as 95% (emergencyPercentage)
+	maxGOGC = math.Min(maxGOGC, calculateGOGC(emergencyPercentage, totalMemSize, liveSize))
 
-	return int(math.Max(minGOGCValue, math.Min(float64(defaultGOGC), maxGOGC)))
+	return int(math.Max(float64(minGOGC), math.Min(float64(defaultGOGC), maxGOGC)))
+}
+
+// smoothGOGC limits the change from the previous GOGC to the target GOGC by the smoothing options of the config,
+// the limits on lowering GOGC are ignored if the memory is tight. The previous GOGC is kept within the same GC cycle.
+func smoothGOGC(prev, target int, tight, sameCycle bool, config Config) int {
+	if prev <= 0 || target <= 0 || (tight && target < prev) || !config.smoothsGOGC() {
+		return target
+	}
+	if sameCycle {
+		return prev
+	}
+	delta := target - prev
+	if math.Abs(float64(delta)) < config.GOGCMinChangePercentage/100*float64(prev) {
+		return prev
+	}
+	if config.GOGCMaxStepUp > 0 && delta > config.GOGCMaxStepUp {
+		return prev + config.GOGCMaxStepUp
+	}
+	if config.GOGCMaxStepDown > 0 && -delta > config.GOGCMaxStepDown {
+		return prev - config.GOGCMaxStepDown
+	}
+	return target
+}
+
+// smoothsGOGC reports whether the GOGC adjustments are smoothed
+func (c *Config) smoothsGOGC() bool {
+	return c.GOGCMinChangePercentage > 0 || c.GOGCMaxStepUp > 0 || c.GOGCMaxStepDown > 0
 }
 
 func calculateGOGC(memoryLimitInPercent float64, memTotal uint64, liveSize float64) float64 {
@@ -107,14 +165,27 @@ func calculateGOGC(memoryLimitInPercent float64, memTotal uint64, liveSize float
 	return (target - liveSize) / liveSize * 100.0
 }
 
-func getMemoryLimit() (uint64, error) {
-	limit := memory.GetMemoryLimit()
+// getMemoryLimit returns the memory limit targeted by the config, with the swap if IncludeSwap is set
+func getMemoryLimit(rt gcruntime.Runtime, config Config) (uint64, error) {
+	limit := rt.ReadMemoryLimit()
 	if limit == 0 {
 		return 0, errors.New("gctuner: failed to get memory limit")
 	}
+	if config.IncludeSwap {
+		limit += rt.ReadSwapLimit()
+	}
 	return limit, nil
 }
 
+// decayPenalty returns the penalty decayed linearly to 0 over decay since the given time
+func decayPenalty(penalty float64, since, now time.Time, decay time.Duration) float64 {
+	if penalty <= 0 {
+		return 0
+	}
+	left := 1 - float64(now.Sub(since))/float64(decay)
+	return penalty * math.Max(math.Min(left, 1), 0)
+}
+
 // printMemorySize prints memory size in a readable format
 func printMemorySize(bytes uint64) string {
 	if bytes == uint64(math.MaxInt64) {